@@ -0,0 +1,146 @@
+// serial-bridge exposes a splitflap's local serial port over TCP, so the backend can run on a different machine and
+// connect with --port=tcp://host:port
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+)
+
+const (
+	minReconnectBackoff = time.Millisecond * 500
+	maxReconnectBackoff = time.Second * 30
+)
+
+// bridge forwards frames from the serial port to whichever TCP client is currently connected, and bytes from that
+// client to the serial port. Only one client is served at a time; a new client replaces the old one
+type bridge struct {
+	serial *usb_serial.Serial
+
+	lock   sync.Mutex
+	client net.Conn
+}
+
+func main() {
+	port := flag.String("port", "", "Serial port the splitflap is connected to")
+	listen := flag.String("listen", ":7070", "Address to accept TCP connections on")
+	flag.Parse()
+
+	if *port == "" {
+		slog.Error("--port is required")
+		os.Exit(1)
+	}
+
+	serial := usb_serial.NewSerialConnectionOnPort(*port)
+	if serial == nil {
+		os.Exit(1)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		slog.Error("Failed to listen", "address", *listen, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Bridging serial port over TCP", "port", *port, "address", ln.Addr().String())
+
+	b := &bridge{serial: serial}
+	go b.serialToClient()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			slog.Error("Failed to accept connection", "error", err)
+			continue
+		}
+		slog.Info("Client connected", "remote", conn.RemoteAddr().String())
+		b.setClient(conn)
+		go b.clientToSerial(conn)
+	}
+}
+
+func (b *bridge) setClient(conn net.Conn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.client != nil {
+		slog.Info("Replacing existing client", "remote", b.client.RemoteAddr().String())
+		b.client.Close()
+	}
+	b.client = conn
+}
+
+func (b *bridge) dropClient(conn net.Conn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	conn.Close()
+	if b.client == conn {
+		b.client = nil
+	}
+}
+
+func (b *bridge) serialToClient() {
+	for {
+		frame, err := b.serial.Read()
+		if err != nil {
+			b.reconnectSerial(err)
+			continue
+		}
+
+		b.lock.Lock()
+		client := b.client
+		b.lock.Unlock()
+
+		// frames are simply dropped while no client is connected; the backend requests state when it connects anyway
+		if client == nil {
+			continue
+		}
+		if _, err = client.Write(frame); err != nil {
+			slog.Info("Client disconnected", "error", err)
+			b.dropClient(client)
+		}
+	}
+}
+
+func (b *bridge) clientToSerial(conn net.Conn) {
+	buffer := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			slog.Info("Client disconnected", "remote", conn.RemoteAddr().String(), "error", err)
+			b.dropClient(conn)
+			return
+		}
+		if err = b.serial.Write(buffer[:n]); err != nil {
+			slog.Error("Failed to write to serial port", "error", err)
+		}
+	}
+}
+
+// reconnectSerial blocks, retrying with exponential backoff, until the serial port can be reopened
+func (b *bridge) reconnectSerial(cause error) {
+	slog.Error("Lost connection to serial port, reconnecting", "error", cause)
+	b.serial.Close()
+
+	backoff := minReconnectBackoff
+	for {
+		time.Sleep(backoff)
+		err := b.serial.Reopen()
+		if err == nil {
+			slog.Info("Reconnected to serial port")
+			return
+		}
+		slog.Info("Failed to reconnect to serial port", "error", err, "retry_in", backoff)
+
+		backoff *= 2
+		if backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
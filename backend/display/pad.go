@@ -2,6 +2,7 @@ package display
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 func LeftPad(str string, size Size) string {
@@ -13,7 +14,8 @@ func RightPad(str string, size Size) string {
 }
 
 func pad(str string, size Size, left bool) string {
-	diff := size.Width*size.Height - len(str)
+	// one module per rune, as some flaps (like °) take more than one byte
+	diff := size.Width*size.Height - utf8.RuneCountInString(str)
 	if diff < 1 {
 		return str
 	}
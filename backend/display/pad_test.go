@@ -29,3 +29,11 @@ func TestLeftPad_multiline(t *testing.T) {
 		t.Errorf("TestLeftPad got \"%s\", want \"%s\"", newStr, "      ABCD")
 	}
 }
+
+func TestRightPad_multibyte(t *testing.T) {
+	size := Size{Width: 6, Height: 1}
+	newStr := RightPad("21°C", size)
+	if newStr != "21°C  " {
+		t.Errorf("TestRightPad got \"%s\", want \"%s\"", newStr, "21°C  ")
+	}
+}
@@ -10,7 +10,10 @@ require (
 	github.com/go-chi/chi/v5 v5.1.0
 	github.com/go-playground/assert/v2 v2.2.0
 	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/joho/godotenv v1.5.1
+	github.com/mochi-mqtt/server/v2 v2.7.9
+	github.com/navidys/gopensky v0.6.0
 	github.com/rs/zerolog v1.33.0
 	go.bug.st/serial v1.6.4
 	google.golang.org/protobuf v1.35.1
@@ -18,11 +21,11 @@ require (
 
 require (
 	github.com/creack/goselect v0.1.2 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
-	github.com/navidys/gopensky v0.6.0 // indirect
+	github.com/rs/xid v1.5.0 // indirect
 	golang.org/x/net v0.36.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,98 @@
+// Package mqtttest runs an embedded MQTT broker on a local port, to test MQTT clients against
+package mqtttest
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// Message is a message published to the broker
+type Message struct {
+	Topic   string
+	Payload string
+	Retain  bool
+}
+
+// Broker accepts connections from anyone until it is closed
+type Broker struct {
+	server   *mqtt.Server
+	listener *listeners.TCP
+	watches  atomic.Int32
+}
+
+// NewBroker starts a broker on a random local port
+func NewBroker() (*Broker, error) {
+	server := mqtt.New(&mqtt.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, err
+	}
+	listener := listeners.NewTCP(listeners.Config{ID: "mqtttest", Address: "127.0.0.1:0"})
+	if err := server.AddListener(listener); err != nil {
+		return nil, err
+	}
+	if err := server.Serve(); err != nil {
+		return nil, err
+	}
+	return &Broker{server: server, listener: listener}, nil
+}
+
+// URL is the address clients connect to the broker at
+func (b *Broker) URL() string {
+	return "tcp://" + b.listener.Address()
+}
+
+// Publish sends a message to every client subscribed to its topic, as if another client published it
+func (b *Broker) Publish(msg Message) error {
+	return b.server.Publish(msg.Topic, []byte(msg.Payload), msg.Retain, 0)
+}
+
+// Retained returns the message retained on a topic, if there is one
+func (b *Broker) Retained(topic string) (Message, bool) {
+	retained := b.server.Topics.Messages(topic)
+	if len(retained) == 0 {
+		return Message{}, false
+	}
+	return Message{Topic: topic, Payload: string(retained[0].Payload), Retain: true}, true
+}
+
+// Watch returns every message published from now on to a topic matching filter. Messages are dropped if the channel
+// is full, rather than holding up the broker
+func (b *Broker) Watch(filter string) <-chan Message {
+	messages := make(chan Message, 100)
+	b.server.Subscribe(filter, int(b.watches.Add(1)), func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		select {
+		case messages <- Message{Topic: pk.TopicName, Payload: string(pk.Payload), Retain: pk.FixedHeader.Retain}:
+		default:
+		}
+	})
+	return messages
+}
+
+// Drop cuts off every connected client as if its connection was lost, so their wills are sent. It returns how many
+// clients were dropped
+func (b *Broker) Drop() int {
+	dropped := 0
+	for _, cl := range b.server.Clients.GetAll() {
+		if cl.Net.Inline {
+			continue
+		}
+		cl.Stop(errors.New("connection dropped by the test"))
+		dropped++
+	}
+	return dropped
+}
+
+// Close stops the broker, and disconnects every client
+func (b *Broker) Close() {
+	b.server.Close()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/denverquane/go-splitflap/display"
 	gen "github.com/denverquane/go-splitflap/serdiev/generated"
 	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
@@ -9,38 +10,56 @@ import (
 	"github.com/denverquane/go-splitflap/splitflap"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 const DisplayFile = "display.json"
 
 func main() {
 	// Command line flags
-	useMock := flag.Bool("mock", true, "Use mock serial connection instead of real hardware")
-	port := flag.String("port", "", "Serial port to connect to when not using mock")
-	flag.Parse()
+	useMock := flag.Bool("mock", true, "Use a simulated splitflap instead of real hardware")
+	port := flag.String("port", "", "Serial port to connect to when not using mock, or tcp://host:port for a serial-bridge. Used for displays that don't configure their own port")
+	simMsPerFlap := flag.Int("sim-ms-per-flap", usb_serial.DefaultSimulatorConfig.MsPerFlap, "How long each simulated module takes to advance one flap")
+	simSensorErrorRate := flag.Float64("sim-sensor-error-rate", 0, "Chance (0-1) that a simulated module reports a sensor error when it arrives")
+	simDropAckRate := flag.Float64("sim-drop-ack-rate", 0, "Chance (0-1) that the simulator doesn't acknowledge a message")
+	record := flag.String("record", "", "Capture all serial traffic with the splitflap to this file")
+	replay := flag.String("replay", "", "Play back the frames from a capture file instead of connecting to a splitflap")
+	replaySpeed := flag.Float64("replay-speed", 1, "Playback speed of --replay; 0 replays without waiting")
+	watch := flag.Duration("watch", 2*time.Second, "How often to check "+DisplayFile+" for changes made outside the server, which are then reloaded; 0 to never reload it")
+	stats := flag.String("stats", "stats.json", "File that module wear statistics are kept in; empty to not keep them")
+	mqttBroker := flag.String("mqtt-broker", "", "MQTT broker to control the displays through, e.g. tcp://localhost:1883; empty to not use MQTT. The MQTT_USERNAME and MQTT_PASSWORD environment variables are used to log in")
+	mqttPrefix := flag.String("mqtt-prefix", "splitflap", "First level of the MQTT topics, e.g. splitflap/{id}/set")
+	mqttDiscovery := flag.String("mqtt-discovery", "homeassistant", "Home Assistant's MQTT discovery prefix, to publish the displays' entities to; empty to not publish them")
 
-	state := make(chan string)
-	handleState := func(stateMsg *gen.SplitflapState) {
-		if len(usb_serial.GlobalAlphabet) == 0 {
-			return
+	// "decode <capture file>" prints a capture in a readable form, instead of running the server
+	if len(os.Args) > 1 && os.Args[1] == "decode" {
+		if len(os.Args) != 3 {
+			slog.Error("usage: decode <capture file>")
+			os.Exit(1)
 		}
-		comb := ""
-		for _, v := range stateMsg.Modules {
-			comb += string(usb_serial.GlobalAlphabet[v.FlapIndex])
+		if err := decodeCapture(os.Args[2]); err != nil {
+			slog.Error("Failed to decode capture", "file", os.Args[2], "error", err)
+			os.Exit(1)
 		}
-		state <- comb
+		return
 	}
+	flag.Parse()
 
-	hub, err := splitflap.LoadDisplayFromFile(DisplayFile)
+	hub, err := splitflap.LoadHubFromFile(DisplayFile)
 	if err != nil {
-		slog.Error("error loading display from json file", "json file", DisplayFile, "error", err.Error())
+		slog.Error("error loading displays from json file", "json file", DisplayFile, "error", err.Error())
 		if _, err = os.Stat(DisplayFile); os.IsNotExist(err) {
 			slog.Info("file not found, creating new display and writing to file", "json file", DisplayFile)
-			hub = splitflap.NewDisplay(display.Size{
+			hub = splitflap.NewHub()
+			err = hub.AddDisplay(splitflap.DefaultDisplayID, splitflap.NewDisplay(display.Size{
 				Width:  12,
 				Height: 1,
-			})
-			err = splitflap.WriteDisplayToFile(hub, DisplayFile)
+			}))
+			if err == nil {
+				err = splitflap.WriteHubToFile(hub, DisplayFile)
+			}
 			if err != nil {
 				slog.Error(err.Error())
 				return
@@ -51,60 +70,177 @@ func main() {
 		}
 	}
 
-	messages := make(chan splitflap.OutMessage)
-
-	var splitflapClient *splitflap.Client
+	// start providers using the poll rate set for their background processing. They are shared by every display
+	if err = hub.StartProviders(); err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	if *watch > 0 {
+		go hub.WatchFile(*watch)
+	}
 
-	// Initialize hardware connection if requested
-	if *useMock || *port != "" {
-		splitflapClient = new(splitflap.Client)
-		*splitflapClient = splitflap.NewSplitflapClient()
+	ids := hub.DisplayIDs()
+	clients := make(map[string]*splitflap.Client)
+	for _, id := range ids {
+		d, _ := hub.Display(id)
+		messages := make(chan splitflap.OutMessage)
+		state := make(chan splitflap.FlapState)
+		handleState := func(stateMsg *gen.SplitflapState) {
+			if len(usb_serial.GlobalAlphabet) == 0 {
+				return
+			}
+			comb := ""
+			moving := make([]bool, len(stateMsg.Modules))
+			for i, v := range stateMsg.Modules {
+				comb += string(usb_serial.GlobalAlphabet[v.FlapIndex])
+				moving[i] = v.Moving
+			}
+			state <- splitflap.FlapState{Text: comb, Moving: moving}
+		}
 
-		var err error
-		if *useMock {
-			modules := hub.Size.Height * hub.Size.Width
-			slog.Info("Using mock serial connection", "modules", modules)
-			err = connectMockSerial(splitflapClient, handleState, modules)
-		} else {
-			slog.Info("Connecting to hardware on port", "port", *port)
-			err = splitflapClient.Connect(*port, handleState)
+		displayPort := d.Port
+		if displayPort == "" {
+			displayPort = *port
 		}
 
-		if err != nil {
-			slog.Error("Failed to connect to splitflap", "error", err.Error())
-			os.Exit(1)
+		// Initialize hardware connection if requested
+		if *useMock || displayPort != "" || *replay != "" {
+			splitflapClient := new(splitflap.Client)
+			*splitflapClient = splitflap.NewSplitflapClient()
+
+			var recorder *usb_serial.Recorder
+			if *record != "" {
+				recordPath := *record
+				if len(ids) > 1 {
+					recordPath = displayFile(recordPath, id)
+				}
+				recordFile, err := os.Create(recordPath)
+				if err != nil {
+					slog.Error("Failed to create capture file", "file", recordPath, "error", err.Error())
+					os.Exit(1)
+				}
+				defer recordFile.Close()
+				slog.Info("Recording serial traffic", "display", id, "file", recordPath)
+				recorder = usb_serial.NewRecorder(recordFile)
+			}
+
+			var err error
+			if *replay != "" {
+				slog.Info("Replaying captured serial traffic", "display", id, "file", *replay, "speed", *replaySpeed)
+				err = connectReplay(splitflapClient, handleState, *replay, *replaySpeed, recorder)
+			} else if *useMock {
+				modules := d.Size.Height * d.Size.Width
+				slog.Info("Using simulated serial connection", "display", id, "modules", modules)
+				err = connectMockSerial(splitflapClient, handleState, modules, usb_serial.SimulatorConfig{
+					MsPerFlap:       *simMsPerFlap,
+					SensorErrorRate: *simSensorErrorRate,
+					DropAckRate:     *simDropAckRate,
+				}, recorder)
+			} else {
+				slog.Info("Connecting to hardware on port", "display", id, "port", displayPort)
+				err = splitflapClient.Connect(displayPort, handleState, recorder)
+			}
+
+			if err != nil {
+				slog.Error("Failed to connect to splitflap", "display", id, "error", err.Error())
+				os.Exit(1)
+			} else {
+				splitflapClient.SetStatusHandler(func(status usb_serial.ConnectionStatus) {
+					server.BroadcastStateChange()
+				})
+				splitflapClient.SetHealthHandler(func(health usb_serial.Health) {
+					server.BroadcastStateChange()
+				})
+				if *stats != "" && *replay == "" {
+					statsPath := *stats
+					if len(ids) > 1 {
+						statsPath = displayFile(statsPath, id)
+					}
+					wear, err := usb_serial.LoadWearTracker(statsPath)
+					if err != nil {
+						slog.Error("Failed to load wear stats", "file", statsPath, "error", err.Error())
+						os.Exit(1)
+					}
+					splitflapClient.SetWearTracker(wear)
+					go wear.SaveEvery(time.Minute)
+				}
+				go splitflapClient.Run(messages)
+			}
+			clients[id] = splitflapClient
 		} else {
-			go splitflapClient.Run(messages)
+			slog.Info("No hardware connection requested, running in software-only mode", "display", id)
+			clients[id] = nil
 		}
-	} else {
-		slog.Info("No hardware connection requested, running in software-only mode")
-	}
 
-	for name, prov := range hub.Providers {
-		// start providers using the poll rate set for their background processing
-		pollRateSecs := prov.BackgroundPollRateSecs
-		prov.Provider.SetPollRateSecs(pollRateSecs)
-		err = prov.Provider.Start()
-		if err != nil {
-			slog.Error("failed to start provider with error", "error", err.Error(), "provider", name)
-			return
-		}
+		go d.Run(messages, state)
 	}
 
-	go hub.Run(messages, state)
+	err = server.Run("3000", hub, clients, server.MQTTConfig{
+		Broker:   *mqttBroker,
+		Username: os.Getenv("MQTT_USERNAME"),
+		Password: os.Getenv("MQTT_PASSWORD"),
+		Prefix:   *mqttPrefix,
 
-	err = server.Run("3000", hub)
+		DiscoveryPrefix: *mqttDiscovery,
+	})
 	if err != nil {
 		slog.Error(err.Error())
 	}
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// displayFile gives each display its own capture or stats file when there are several displays, e.g. capture.jsonl
+// becomes capture-lobby.jsonl
+func displayFile(path, id string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + id + ext
 }
 
-// Connect to a mock serial device
-func connectMockSerial(client *splitflap.Client, notify func(state *gen.SplitflapState), modules int) error {
-	mockConn := usb_serial.NewMockConnection(modules)
-	sf := usb_serial.NewSplitflap(mockConn, notify, modules)
+// Connect to a simulated serial device
+func connectMockSerial(client *splitflap.Client, notify func(state *gen.SplitflapState), modules int, config usb_serial.SimulatorConfig, recorder *usb_serial.Recorder) error {
+	simConn := usb_serial.NewSimulatorConnection(modules, config)
+	sf := usb_serial.NewSplitflap(simConn, notify, modules)
+	if recorder != nil {
+		sf.SetRecorder(recorder)
+	}
 	sf.Start()
 
 	client.SetSerial(sf)
 	return nil
 }
+
+// Play back a capture file as if it was coming from a splitflap
+func connectReplay(client *splitflap.Client, notify func(state *gen.SplitflapState), file string, speed float64, recorder *usb_serial.Recorder) error {
+	replayConn, err := usb_serial.NewReplayConnection(file, speed)
+	if err != nil {
+		return err
+	}
+	sf := usb_serial.NewSplitflap(replayConn, notify, 0)
+	if recorder != nil {
+		sf.SetRecorder(recorder)
+	}
+	sf.Start()
+
+	client.SetSerial(sf)
+	return nil
+}
+
+// Print every frame of a capture file, one per line
+func decodeCapture(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	frames, err := usb_serial.ReadCapture(f)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		fmt.Println(frame.String())
+	}
+	return nil
+}
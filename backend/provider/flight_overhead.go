@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"github.com/navidys/gopensky"
 	"log/slog"
 	"sync"
@@ -20,10 +21,50 @@ type FlightsOverheadProvider struct {
 	lastRefresh  time.Time
 	nextRefresh  time.Time
 	current      string
+	updated      time.Time
 	kill         chan struct{}
 	lock         sync.RWMutex
 }
 
+func (fo *FlightsOverheadProvider) Check() error {
+	if fo.Latitude < -90 || fo.Latitude > 90 || fo.Longitude < -180 || fo.Longitude > 180 {
+		return errors.New("latitude must be within [-90, 90] and longitude within [-180, 180]")
+	}
+	if fo.LatRange <= 0 || fo.LonRange <= 0 {
+		return errors.New("lat_range and lon_range must be positive")
+	}
+	return nil
+}
+
+func (fo *FlightsOverheadProvider) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "Latitude",
+			Description: "The latitude of the center of the area to watch for flights",
+			Field:       "latitude",
+			Type:        "float",
+		},
+		{
+			Name:        "Longitude",
+			Description: "The longitude of the center of the area to watch for flights",
+			Field:       "longitude",
+			Type:        "float",
+		},
+		{
+			Name:        "Latitude Range",
+			Description: "How many degrees of latitude either side of the center to watch",
+			Field:       "lat_range",
+			Type:        "float",
+		},
+		{
+			Name:        "Longitude Range",
+			Description: "How many degrees of longitude either side of the center to watch",
+			Field:       "lon_range",
+			Type:        "float",
+		},
+	}
+}
+
 func (fo *FlightsOverheadProvider) SetPollRateSecs(rate int) {
 	fo.lock.Lock()
 	defer fo.lock.Unlock()
@@ -83,6 +124,9 @@ func (fo *FlightsOverheadProvider) Start() error {
 						fo.current = v.Icao24
 					}
 
+					if err == nil {
+						fo.updated = now
+					}
 					fo.lastRefresh = now
 					fo.nextRefresh = now.Add(time.Second * time.Duration(fo.pollRateSecs))
 
@@ -104,6 +148,7 @@ func (fo *FlightsOverheadProvider) Values() PValues {
 	defer fo.lock.RUnlock()
 
 	return PValues{
-		"current": fo.current,
+		"current":    fo.current,
+		UpdatedValue: fo.updated,
 	}
 }
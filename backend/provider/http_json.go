@@ -0,0 +1,371 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HTTP_JSON ProviderType = "HTTP_JSON"
+
+// how long a request can take before it is given up on, unless the provider sets its own timeout
+const httpJSONDefaultTimeout = time.Second * 10
+
+// HTTPJSONProvider polls a URL that returns JSON, and supplies the values picked out of it by its selectors
+type HTTPJSONProvider struct {
+	URL         string            `json:"url"`
+	Method      string            `json:"method"` // GET if empty
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	Auth        HTTPAuth          `json:"auth"`
+	Selectors   map[string]string `json:"values"` // value name -> selector, e.g. "data.items.0.count"
+	TimeoutSecs int               `json:"timeout_secs"`
+
+	pollRateSecs int
+	lastRefresh  time.Time
+	nextRefresh  time.Time
+	values       PValues
+	updated      time.Time
+	cancel       context.CancelFunc // stops the poll loop, and any request in flight
+	lock         sync.RWMutex
+}
+
+// HTTPAuth is how an HTTPJSONProvider authenticates: with a bearer token, a username and password, or not at all
+type HTTPAuth struct {
+	Type     string `json:"type"` // "", "bearer" or "basic"
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (hp *HTTPJSONProvider) Check() error {
+	// the whole URL can come from the environment, in which case it can only be checked once it's known
+	if !strings.HasPrefix(hp.URL, "http://") && !strings.HasPrefix(hp.URL, "https://") && !strings.HasPrefix(hp.URL, "${") {
+		return errors.New("field config.url: must be an http:// or https:// URL")
+	}
+	switch strings.ToUpper(hp.Method) {
+	case "", http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return errors.New("field config.method: must be GET, POST, PUT or PATCH")
+	}
+	switch hp.Auth.Type {
+	case "":
+	case "bearer":
+		if hp.Auth.Token == "" {
+			return errors.New("field config.auth.token: must be set for bearer auth")
+		}
+	case "basic":
+		if hp.Auth.Username == "" {
+			return errors.New("field config.auth.username: must be set for basic auth")
+		}
+	default:
+		return errors.New("field config.auth.type: must be bearer, basic or empty")
+	}
+	if len(hp.Selectors) == 0 {
+		return errors.New("field config.values: must select at least one value")
+	}
+	for name, selector := range hp.Selectors {
+		if selector == "" {
+			return errors.New("field config.values." + name + ": selector cannot be empty")
+		}
+	}
+	if hp.TimeoutSecs < 0 {
+		return errors.New("field config.timeout_secs: cannot be negative")
+	}
+	return nil
+}
+
+func (hp *HTTPJSONProvider) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "URL",
+			Description: "The URL to poll, which must return JSON. ${NAME} is replaced by the environment variable NAME",
+			Field:       "url",
+			Type:        "string",
+		},
+		{
+			Name:        "Method",
+			Description: "The HTTP method to poll with: GET (the default), POST, PUT or PATCH",
+			Field:       "method",
+			Type:        "string",
+		},
+		{
+			Name:        "Headers",
+			Description: "Headers to send with every request. ${NAME} is replaced by the environment variable NAME",
+			Field:       "headers",
+			Type:        "{string: string}",
+		},
+		{
+			Name:        "Body",
+			Description: "The body to send with every request, if any. ${NAME} is replaced by the environment variable NAME",
+			Field:       "body",
+			Type:        "string",
+		},
+		{
+			Name:        "Auth",
+			Description: "How to authenticate: a bearer token, or a basic auth username and password. Use ${NAME} to keep secrets in environment variables",
+			Field:       "auth",
+			Type:        "{\"type\": \"bearer\" | \"basic\", \"token\": string, \"username\": string, \"password\": string}",
+		},
+		{
+			Name:        "Values",
+			Description: "The values to supply, by name, each picked out of the response by a selector like \"data.items.0.count\" or \"$.data.items[0].count\" (\"items.#\" is the length of items)",
+			Field:       "values",
+			Type:        "{string: string}",
+		},
+		{
+			Name:        "Timeout",
+			Description: "How many seconds a request can take before it is given up on (10 if not set)",
+			Field:       "timeout_secs",
+			Type:        "int",
+		},
+	}
+}
+
+func (hp *HTTPJSONProvider) SetPollRateSecs(rate int) {
+	hp.lock.Lock()
+	defer hp.lock.Unlock()
+
+	// unlike the providers of public APIs, this is often pointed at a local service, so it can poll as often as it likes
+	hp.pollRateSecs = rate
+	if hp.pollRateSecs < 1 {
+		slog.Info("http_json provider poll rate is < 1sec, setting to minimum of 1")
+		hp.pollRateSecs = 1
+	}
+	hp.nextRefresh = hp.lastRefresh.Add(time.Duration(hp.pollRateSecs) * time.Second)
+}
+
+func (hp *HTTPJSONProvider) Start() error {
+	request, err := hp.buildRequest()
+	if err != nil {
+		return err
+	}
+	timeout := httpJSONDefaultTimeout
+	if hp.TimeoutSecs > 0 {
+		timeout = time.Duration(hp.TimeoutSecs) * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hp.lock.Lock()
+	hp.cancel = cancel
+	// make the next refresh 0 so we refresh immediately
+	hp.nextRefresh = time.Time{}
+	hp.lock.Unlock()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("http_json provider received kill signal, exiting", "url", hp.URL)
+				return
+			default:
+				now := time.Now()
+
+				hp.lock.RLock()
+				refresh := now.After(hp.nextRefresh)
+				hp.lock.RUnlock()
+
+				if refresh {
+					values, err := hp.fetch(ctx, client, request)
+					if ctx.Err() != nil {
+						// stopped mid-request, which isn't a failure to fetch
+						continue
+					}
+
+					hp.lock.Lock()
+					if err != nil {
+						slog.Error("http_json provider failed to fetch values", "url", hp.URL, "error", err.Error())
+					} else {
+						hp.values = values
+						hp.updated = now
+					}
+					hp.lastRefresh = now
+					hp.nextRefresh = now.Add(time.Second * time.Duration(hp.pollRateSecs))
+					hp.lock.Unlock()
+				}
+				time.Sleep(time.Millisecond * 100)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop returns straight away, cancelling the request in flight if there is one
+func (hp *HTTPJSONProvider) Stop() {
+	hp.lock.RLock()
+	cancel := hp.cancel
+	hp.lock.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (hp *HTTPJSONProvider) Values() PValues {
+	hp.lock.RLock()
+	defer hp.lock.RUnlock()
+
+	values := make(PValues, len(hp.values)+1)
+	for name, value := range hp.values {
+		values[name] = value
+	}
+	values[UpdatedValue] = hp.updated
+	return values
+}
+
+// httpJSONRequest is everything needed to make a request, with the secrets filled in
+type httpJSONRequest struct {
+	method  string
+	url     string
+	headers http.Header
+	body    string
+}
+
+// buildRequest fills the secrets into the request the provider makes, failing if any of them aren't set
+func (hp *HTTPJSONProvider) buildRequest() (httpJSONRequest, error) {
+	secrets := &secretExpander{}
+	expand := secrets.expand
+
+	request := httpJSONRequest{
+		method:  strings.ToUpper(hp.Method),
+		url:     expand(hp.URL),
+		headers: make(http.Header),
+		body:    expand(hp.Body),
+	}
+	if request.method == "" {
+		request.method = http.MethodGet
+	}
+	for name, value := range hp.Headers {
+		request.headers.Set(name, expand(value))
+	}
+	if request.body != "" && request.headers.Get("Content-Type") == "" {
+		request.headers.Set("Content-Type", "application/json")
+	}
+	request.headers.Set("Accept", "application/json")
+
+	switch hp.Auth.Type {
+	case "bearer":
+		request.headers.Set("Authorization", "Bearer "+expand(hp.Auth.Token))
+	case "basic":
+		credentials := expand(hp.Auth.Username) + ":" + expand(hp.Auth.Password)
+		request.headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	if err := secrets.err(HTTP_JSON); err != nil {
+		return httpJSONRequest{}, err
+	}
+	return request, nil
+}
+
+// fetch makes the request, and picks the values out of the response
+func (hp *HTTPJSONProvider) fetch(ctx context.Context, client *http.Client, request httpJSONRequest) (PValues, error) {
+	req, err := http.NewRequestWithContext(ctx, request.method, request.url, bytes.NewBufferString(request.body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = request.headers.Clone()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errors.New("unexpected response status " + resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc any
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, errors.New("response is not valid JSON: " + err.Error())
+	}
+
+	values := make(PValues, len(hp.Selectors))
+	for name, selector := range hp.Selectors {
+		value, ok := SelectJSON(doc, selector)
+		if !ok {
+			slog.Warn("http_json provider selector didn't match the response", "url", hp.URL, "value", name, "selector", selector)
+			continue
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// SelectJSON picks a value out of decoded JSON with a selector: keys and array indexes separated by dots, like
+// "data.items.0.count". "#" is the length of an array, and a "." in a key is escaped as "\.". JSONPath style selectors
+// like "$.data.items[0].count" work too. Numbers come out as float64, and objects and arrays as JSON strings
+func SelectJSON(doc any, selector string) (any, bool) {
+	selector = strings.TrimPrefix(selector, "$")
+	selector = strings.NewReplacer("[", ".", "]", "").Replace(selector)
+	selector = strings.TrimPrefix(selector, ".")
+
+	current := doc
+	for _, key := range splitSelector(selector) {
+		switch node := current.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []any:
+			if key == "#" {
+				current = json.Number(strconv.Itoa(len(node)))
+				continue
+			}
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+
+	switch value := current.(type) {
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case map[string]any, []any:
+		raw, err := json.Marshal(value)
+		return string(raw), err == nil
+	}
+	return current, true
+}
+
+// splitSelector splits a selector at every dot that isn't escaped
+func splitSelector(selector string) []string {
+	if selector == "" {
+		return nil
+	}
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(selector); i++ {
+		switch {
+		case selector[i] == '\\' && i+1 < len(selector) && selector[i+1] == '.':
+			key.WriteByte('.')
+			i++
+		case selector[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(selector[i])
+		}
+	}
+	return append(keys, key.String())
+}
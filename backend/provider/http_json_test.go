@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSelectJSON(t *testing.T) {
+	var doc any
+	err := json.Unmarshal([]byte(`{"data": {"items": [{"count": 3}, {"count": 5}], "a.b": "dotted", "ok": true}}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for selector, expected := range map[string]any{
+		"data.items.1.count":     float64(5),
+		"$.data.items[0].count":  float64(3),
+		"data.items.#":           float64(2),
+		`data.a\.b`:              "dotted",
+		"data.ok":                true,
+		"data.items.0":           `{"count":3}`,
+		"data.items.2.count":     nil,
+		"data.missing":           nil,
+		"data.ok.deeper":         nil,
+		"$.data.items[-1].count": nil,
+	} {
+		value, ok := SelectJSON(doc, selector)
+		if expected == nil {
+			if ok {
+				t.Error("selector", selector, "shouldn't match anything, got", value)
+			}
+		} else if value != expected {
+			t.Error("selector", selector, "expected", expected, "got", value)
+		}
+	}
+}
+
+func TestHTTPJSONProvider(t *testing.T) {
+	t.Setenv("HTTP_JSON_TEST_TOKEN", "secret")
+
+	requests := make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"query": "builds"}` {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		requests <- r
+		w.Write([]byte(`{"builds": {"passing": 41, "failing": 1, "latest": "v1.2"}}`))
+	}))
+	defer server.Close()
+
+	hp := &HTTPJSONProvider{
+		URL:       server.URL,
+		Method:    "post",
+		Headers:   map[string]string{"X-Team": "display"},
+		Body:      `{"query": "builds"}`,
+		Auth:      HTTPAuth{Type: "bearer", Token: "${HTTP_JSON_TEST_TOKEN}"},
+		Selectors: map[string]string{"passing": "builds.passing", "latest": "$.builds.latest", "missing": "builds.x"},
+	}
+	if err := hp.Check(); err != nil {
+		t.Fatal(err)
+	}
+	hp.SetPollRateSecs(60)
+	if err := hp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer hp.Stop()
+
+	select {
+	case r := <-requests:
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Team") != "display" {
+			t.Fatal("headers and secrets should be sent", r.Header)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("provider should poll as soon as it starts")
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for hp.Values()["passing"] == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	values := hp.Values()
+	if values["passing"] != float64(41) || values["latest"] != "v1.2" {
+		t.Fatal("selected values should be supplied", values)
+	}
+	if _, ok := values["missing"]; ok {
+		t.Fatal("a selector that doesn't match shouldn't supply a value")
+	}
+}
+
+func TestHTTPJSONProvider_missingSecret(t *testing.T) {
+	hp := &HTTPJSONProvider{
+		URL:       "http://localhost/",
+		Auth:      HTTPAuth{Type: "basic", Username: "admin", Password: "${HTTP_JSON_TEST_UNSET}"},
+		Selectors: map[string]string{"value": "value"},
+	}
+	if err := hp.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if err := hp.Start(); err == nil {
+		t.Fatal("starting without the secrets it references should fail")
+	}
+}
+
+func TestHTTPJSONProvider_stopDuringRequest(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		// answers only once the request is given up on
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	hp := &HTTPJSONProvider{URL: server.URL, Selectors: map[string]string{"value": "value"}, TimeoutSecs: 60}
+	hp.SetPollRateSecs(60)
+	if err := hp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-requested:
+	case <-time.After(time.Second * 5):
+		t.Fatal("provider should poll as soon as it starts")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		hp.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatal("stopping shouldn't wait for the request in flight")
+	}
+}
+
+func TestHTTPJSONProvider_stopWhileStarting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"value": 1}`))
+	}))
+	defer server.Close()
+
+	hp := &HTTPJSONProvider{URL: server.URL, Selectors: map[string]string{"value": "value"}}
+	hp.SetPollRateSecs(60)
+	started := make(chan error)
+	go func() {
+		started <- hp.Start()
+	}()
+	// e.g. the config being reloaded as the provider starts
+	hp.Stop()
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	hp.Stop()
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+)
+
+const MQTT ProviderType = "MQTT"
+
+// how long to wait for the broker to acknowledge a connection or subscription
+const mqttTimeout = time.Second * 10
+
+// MQTTProvider subscribes to topics on an MQTT broker, and supplies the latest payload of each as a value. Values are
+// pushed by the broker as they change, so there is nothing to poll
+type MQTTProvider struct {
+	Broker   string               `json:"broker"` // e.g. tcp://localhost:1883
+	Username string               `json:"username"`
+	Password string               `json:"password"`
+	Topics   map[string]MQTTValue `json:"values"` // value name -> the topic it comes from
+
+	client  mqtt.Client
+	values  PValues
+	updated time.Time
+	lock    sync.RWMutex
+}
+
+// MQTTValue is where one value of an MQTTProvider comes from: the whole payload of a topic, or one field of a JSON
+// payload
+type MQTTValue struct {
+	Topic    string `json:"topic"`
+	Selector string `json:"selector"` // picks a field out of a JSON payload, like HTTP_JSON's values. Empty for the whole payload
+}
+
+func (mp *MQTTProvider) Check() error {
+	if !strings.Contains(mp.Broker, "://") && !strings.HasPrefix(mp.Broker, "${") {
+		return errors.New("field config.broker: must be a URL like tcp://localhost:1883")
+	}
+	if len(mp.Topics) == 0 {
+		return errors.New("field config.values: must subscribe to at least one topic")
+	}
+	for name, value := range mp.Topics {
+		if value.Topic == "" {
+			return errors.New("field config.values." + name + ".topic: cannot be empty")
+		}
+	}
+	return nil
+}
+
+func (mp *MQTTProvider) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "Broker",
+			Description: "The URL of the MQTT broker, e.g. tcp://localhost:1883 or ssl://broker:8883. ${NAME} is replaced by the environment variable NAME",
+			Field:       "broker",
+			Type:        "string",
+		},
+		{
+			Name:        "Username",
+			Description: "The username to connect with, if the broker needs one. ${NAME} is replaced by the environment variable NAME",
+			Field:       "username",
+			Type:        "string",
+		},
+		{
+			Name:        "Password",
+			Description: "The password to connect with. Use ${NAME} to keep it in an environment variable",
+			Field:       "password",
+			Type:        "string",
+		},
+		{
+			Name:        "Values",
+			Description: "The values to supply, by name, each the latest payload of a topic. A number payload is supplied as a number. If the payload is JSON, a selector like \"data.temperature\" picks a field out of it",
+			Field:       "values",
+			Type:        "{string: {\"topic\": string, \"selector\": string}}",
+		},
+	}
+}
+
+// SetPollRateSecs does nothing, as the broker sends values as soon as they change
+func (mp *MQTTProvider) SetPollRateSecs(rate int) {}
+
+func (mp *MQTTProvider) Start() error {
+	secrets := &secretExpander{}
+	opts := mqtt.NewClientOptions().
+		AddBroker(secrets.expand(mp.Broker)).
+		SetClientID("splitflap-" + uuid.NewString()).
+		SetUsername(secrets.expand(mp.Username)).
+		SetPassword(secrets.expand(mp.Password)).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+	if err := secrets.err(MQTT); err != nil {
+		return err
+	}
+
+	mp.lock.Lock()
+	mp.values = make(PValues, len(mp.Topics))
+	mp.lock.Unlock()
+
+	// subscriptions don't outlive a connection, so they are made again whenever the client reconnects
+	opts.SetOnConnectHandler(func(client mqtt.Client) {
+		for topic, names := range mp.valuesByTopic() {
+			token := client.Subscribe(topic, 0, func(client mqtt.Client, msg mqtt.Message) {
+				mp.update(names, msg.Payload())
+			})
+			if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+				slog.Error("mqtt provider failed to subscribe", "broker", mp.Broker, "topic", topic, "error", token.Error().Error())
+			}
+		}
+	})
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		slog.Warn("mqtt provider lost its connection, reconnecting", "broker", mp.Broker, "error", err.Error())
+	})
+
+	// the client keeps trying to connect in the background, so a broker that is down doesn't stop the server starting
+	mp.client = mqtt.NewClient(opts)
+	mp.client.Connect()
+	return nil
+}
+
+func (mp *MQTTProvider) Stop() {
+	mp.client.Disconnect(250)
+	slog.Info("mqtt provider disconnected", "broker", mp.Broker)
+}
+
+func (mp *MQTTProvider) Values() PValues {
+	mp.lock.RLock()
+	defer mp.lock.RUnlock()
+
+	values := make(PValues, len(mp.values)+1)
+	for name, value := range mp.values {
+		values[name] = value
+	}
+	values[UpdatedValue] = mp.updated
+	return values
+}
+
+// valuesByTopic groups the names of the values by the topic they come from, so each topic is only subscribed to once
+func (mp *MQTTProvider) valuesByTopic() map[string][]string {
+	topics := make(map[string][]string)
+	for name, value := range mp.Topics {
+		topics[value.Topic] = append(topics[value.Topic], name)
+	}
+	return topics
+}
+
+// update sets the values that come from a topic from a payload it received
+func (mp *MQTTProvider) update(names []string, payload []byte) {
+	mp.lock.Lock()
+	defer mp.lock.Unlock()
+
+	for _, name := range names {
+		value, ok := ParsePayload(payload, mp.Topics[name].Selector)
+		if !ok {
+			slog.Warn("mqtt provider selector didn't match the payload", "topic", mp.Topics[name].Topic, "value", name)
+			continue
+		}
+		mp.values[name] = value
+		mp.updated = time.Now()
+	}
+}
+
+// ParsePayload turns an MQTT payload into a value. Without a selector the whole payload is the value, as a number if
+// it is one, otherwise as a string. With a selector, the payload must be JSON, and the selector picks the value out of
+// it like SelectJSON does
+func ParsePayload(payload []byte, selector string) (any, bool) {
+	if selector == "" {
+		text := strings.TrimSpace(string(payload))
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, true
+		}
+		return text, true
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return SelectJSON(doc, selector)
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/internal/mqtttest"
+)
+
+func TestMQTTProvider(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	t.Setenv("MQTT_TEST_BROKER", broker.URL())
+
+	// a retained payload is supplied as soon as the provider subscribes
+	broker.Publish(mqtttest.Message{Topic: "home/garden/temperature", Payload: "21.5\n", Retain: true})
+
+	mp := &MQTTProvider{
+		Broker: "${MQTT_TEST_BROKER}",
+		Topics: map[string]MQTTValue{
+			"temperature": {Topic: "home/garden/temperature"},
+			"humidity":    {Topic: "home/+/sensor", Selector: "readings.humidity"},
+			"status":      {Topic: "home/+/sensor", Selector: "status"},
+		},
+	}
+	if err = mp.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if err = mp.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer mp.Stop()
+
+	waitFor := func(name string, want any) {
+		deadline := time.Now().Add(time.Second * 5)
+		for mp.Values()[name] != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if got := mp.Values()[name]; got != want {
+			t.Fatalf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+	waitFor("temperature", 21.5)
+
+	// the provider may still be subscribing, so keep publishing until the payload gets through
+	deadline := time.Now().Add(time.Second * 5)
+	for mp.Values()["status"] == nil && time.Now().Before(deadline) {
+		broker.Publish(mqtttest.Message{Topic: "home/shed/sensor", Payload: `{"readings": {"humidity": 60}, "status": "ok"}`})
+		time.Sleep(time.Millisecond * 50)
+	}
+	waitFor("humidity", float64(60))
+	waitFor("status", "ok")
+
+	broker.Publish(mqtttest.Message{Topic: "home/shed/sensor", Payload: "not json"})
+	broker.Publish(mqtttest.Message{Topic: "home/garden/temperature", Payload: "warm"})
+	waitFor("temperature", "warm")
+	if mp.Values()["status"] != "ok" {
+		t.Fatal("a payload the selector doesn't match should keep the last value")
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	for _, test := range []struct {
+		payload, selector string
+		value             any
+		ok                bool
+	}{
+		{"42", "", float64(42), true},
+		{" on \r\n", "", "on", true},
+		{`{"a": [1, {"b": "x"}]}`, "a.1.b", "x", true},
+		{`{"a": 1}`, "b", nil, false},
+		{"plain", "a", nil, false},
+	} {
+		value, ok := ParsePayload([]byte(test.payload), test.selector)
+		if value != test.value || ok != test.ok {
+			t.Errorf("ParsePayload(%q, %q) = %v, %v; expected %v, %v", test.payload, test.selector, value, ok, test.value, test.ok)
+		}
+	}
+}
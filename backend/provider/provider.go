@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"strconv"
 )
 
 type ProviderJSON struct {
@@ -23,6 +24,8 @@ type Provider struct {
 // iface is the interface that any new providers should conform to. It should be able to stop and start, and provide
 // any data via the Values call
 type iface interface {
+	Check() error            // validates the provider's config, before it is started
+	Parameters() []Parameter // describes the provider's config, so it can be edited
 	Start() error
 	SetPollRateSecs(int)
 	Stop()
@@ -31,9 +34,21 @@ type iface interface {
 
 type ProviderType string
 
+// Parameter describes one field of a provider's config
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Field       string `json:"field"`
+	Type        string `json:"type"`
+}
+
 // simple key/value pairing of any values a given provider supplies
 type PValues map[string]any
 
+// UpdatedValue is the value that a provider supplies the time it last fetched its values successfully as, if it knows
+// it. It is a time.Time, and zero until the first fetch succeeds, so routines can tell when values are missing or stale
+const UpdatedValue = "_updated"
+
 // ProviderValues is a mapping of Provider names (there can be multiple instances of a given provider type) to values
 // that the provider supplies
 type ProviderValues map[string]PValues
@@ -46,12 +61,12 @@ func (p *Provider) UnmarshalJSON(data []byte) error {
 	}
 
 	if rout, ok := AllProviders[aux.Type]; !ok {
-		return errors.New("unrecognized routine type")
+		return errors.New("unrecognized provider type " + strconv.Quote(string(aux.Type)))
 	} else {
 		var newProv iface
 		newProv = reflect.New(reflect.ValueOf(rout).Elem().Type()).Interface().(iface)
 		if err := json.Unmarshal(aux.Provider, newProv); err != nil {
-			return err
+			return ConfigFieldError(err)
 		}
 		p.ActivePollRateSecs = aux.ActivePollRateSecs
 		p.BackgroundPollRateSecs = aux.BackgroundPollRateSecs
@@ -62,8 +77,31 @@ func (p *Provider) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ConfigFieldError makes an error from unmarshalling the config of a provider or routine name the field it came from
+// relative to the whole provider or routine, e.g. config.location_id rather than location_id
+func ConfigFieldError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		typeErr.Field = "config." + typeErr.Field
+	}
+	return err
+}
+
+// Check validates the poll rates and the config of the provider
+func (p *Provider) Check() error {
+	if _, ok := AllProviders[p.Type]; !ok {
+		return errors.New("unrecognized provider type " + strconv.Quote(string(p.Type)))
+	}
+	if p.ActivePollRateSecs < 0 || p.BackgroundPollRateSecs < 0 {
+		return errors.New("poll rates cannot be negative")
+	}
+	return p.Provider.Check()
+}
+
 var AllProviders = map[ProviderType]iface{
 	WEATHER_CURRENT:  &WeatherCurrentProvider{},
 	WEATHER_FORECAST: &WeatherForecastProvider{},
 	FLIGHTS_OVERHEAD: &FlightsOverheadProvider{},
+	HTTP_JSON:        &HTTPJSONProvider{},
+	MQTT:             &MQTTProvider{},
 }
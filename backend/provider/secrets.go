@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// secrets are referenced as ${NAME}, and read from the environment when the provider starts
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretExpander fills in the secrets referenced by a provider's config, remembering any that aren't set
+type secretExpander struct {
+	missing []string
+}
+
+func (e *secretExpander) expand(str string) string {
+	return envReference.ReplaceAllStringFunc(str, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			e.missing = append(e.missing, name)
+		}
+		return value
+	})
+}
+
+// err fails if any of the secrets that were expanded aren't set
+func (e *secretExpander) err(providerType ProviderType) error {
+	if len(e.missing) == 0 {
+		return nil
+	}
+	return errors.New("environment variables " + strings.Join(e.missing, ", ") +
+		" are not set, can't start " + strings.ToLower(string(providerType)) + " provider")
+}
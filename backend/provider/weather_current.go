@@ -19,10 +19,38 @@ type WeatherCurrentProvider struct {
 	lastRefresh  time.Time
 	nextRefresh  time.Time
 	current      float64
+	updated      time.Time
 	kill         chan struct{}
 	lock         sync.RWMutex
 }
 
+func (wp *WeatherCurrentProvider) Check() error {
+	if wp.LocationID == 0 {
+		return errors.New("location_id must be set")
+	}
+	if !openweathermap.ValidDataUnit(wp.Units) {
+		return errors.New("units must be one of C, F or K")
+	}
+	return nil
+}
+
+func (wp *WeatherCurrentProvider) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "Location ID",
+			Description: "The OpenWeatherMap ID of the city to report the current temperature of",
+			Field:       "location_id",
+			Type:        "int",
+		},
+		{
+			Name:        "Units",
+			Description: "The units of the temperatures: C, F or K",
+			Field:       "units",
+			Type:        "string",
+		},
+	}
+}
+
 func (wp *WeatherCurrentProvider) SetPollRateSecs(rate int) {
 	wp.lock.Lock()
 	defer wp.lock.Unlock()
@@ -75,6 +103,7 @@ func (wp *WeatherCurrentProvider) Start() error {
 					} else {
 						slog.Info("weather_current provider reported temps", "current", cur, "units", wp.Units)
 						wp.current = cur
+						wp.updated = now
 					}
 
 					wp.lastRefresh = now
@@ -98,7 +127,8 @@ func (wp *WeatherCurrentProvider) Values() PValues {
 	defer wp.lock.RUnlock()
 
 	return PValues{
-		"current": wp.current,
-		"units":   wp.Units,
+		"current":    wp.current,
+		"units":      wp.Units,
+		UpdatedValue: wp.updated,
 	}
 }
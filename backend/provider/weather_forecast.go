@@ -19,10 +19,38 @@ type WeatherForecastProvider struct {
 	lastRefresh  time.Time
 	nextRefresh  time.Time
 	low, high    float64
+	updated      time.Time
 	kill         chan struct{}
 	lock         sync.RWMutex
 }
 
+func (wp *WeatherForecastProvider) Check() error {
+	if wp.LocationID == 0 {
+		return errors.New("location_id must be set")
+	}
+	if !openweathermap.ValidDataUnit(wp.Units) {
+		return errors.New("units must be one of C, F or K")
+	}
+	return nil
+}
+
+func (wp *WeatherForecastProvider) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "Location ID",
+			Description: "The OpenWeatherMap ID of the city to report the forecast low and high of",
+			Field:       "location_id",
+			Type:        "int",
+		},
+		{
+			Name:        "Units",
+			Description: "The units of the temperatures: C, F or K",
+			Field:       "units",
+			Type:        "string",
+		},
+	}
+}
+
 func (wp *WeatherForecastProvider) SetPollRateSecs(rate int) {
 	wp.lock.Lock()
 	defer wp.lock.Unlock()
@@ -82,6 +110,7 @@ func (wp *WeatherForecastProvider) Start() error {
 						slog.Info("weather_forecast provider reported temps", "low", low, "high", high)
 						wp.low = low
 						wp.high = high
+						wp.updated = now
 					}
 
 					wp.lastRefresh = now
@@ -105,8 +134,9 @@ func (wp *WeatherForecastProvider) Values() PValues {
 	defer wp.lock.RUnlock()
 
 	return PValues{
-		"low":   wp.low,
-		"high":  wp.high,
-		"units": wp.Units,
+		"low":        wp.low,
+		"high":       wp.high,
+		"units":      wp.Units,
+		UpdatedValue: wp.updated,
 	}
 }
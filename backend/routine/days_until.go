@@ -10,6 +10,9 @@ import (
 
 const DAYSUNTIL = "DAYSUNTIL"
 
+// end dates are saved as YYYY-MM-DD, but MM/DD/YYYY, which older clients send, is still accepted
+var endDateLayouts = []string{time.DateOnly, "01/02/2006"}
+
 type DaysUntilRoutine struct {
 	End string `json:"end_date"`
 
@@ -23,6 +26,9 @@ func (d *DaysUntilRoutine) SizeRange() (display.Min, display.Max) {
 }
 
 func (d *DaysUntilRoutine) Check() error {
+	if _, err := parseEndDate(d.End); err != nil {
+		return errors.New("field config.end_date: must be a date in YYYY-MM-DD or MM/DD/YYYY format")
+	}
 	return nil
 }
 
@@ -30,7 +36,7 @@ func (d *DaysUntilRoutine) Init(size display.Size) error {
 	if !supportsSize(d, size) {
 		return errors.New("routine does not support that size")
 	}
-	end, err := time.Parse("01/02/2006", d.End)
+	end, err := parseEndDate(d.End)
 	if err != nil {
 		return err
 	}
@@ -42,6 +48,17 @@ func (d *DaysUntilRoutine) Init(size display.Size) error {
 	return nil
 }
 
+func parseEndDate(end string) (time.Time, error) {
+	var err error
+	for _, layout := range endDateLayouts {
+		var date time.Time
+		if date, err = time.Parse(layout, end); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, err
+}
+
 func (d *DaysUntilRoutine) Update(now time.Time, _ provider.ProviderValues) *Message {
 	if now.Sub(d.lastUpdate) < time.Minute {
 		return nil
@@ -60,7 +77,7 @@ func (d *DaysUntilRoutine) Parameters() []Parameter {
 	return []Parameter{
 		{
 			Name:        "End Date",
-			Description: "The end date the routine is counting down to, in MM/DD/YYYY format",
+			Description: "The end date the routine is counting down to, in YYYY-MM-DD format. MM/DD/YYYY is also accepted",
 			Field:       "end_date",
 			Type:        "string",
 		},
@@ -0,0 +1,18 @@
+package routine
+
+import "testing"
+
+func TestDaysUntilRoutine_Check(t *testing.T) {
+	for _, end := range []string{"2030-12-25", "12/25/2030"} {
+		d := DaysUntilRoutine{End: end}
+		if err := d.Check(); err != nil {
+			t.Error(end, err)
+		}
+	}
+	for _, end := range []string{"", "25/12/2030", "Christmas"} {
+		d := DaysUntilRoutine{End: end}
+		if err := d.Check(); err == nil {
+			t.Error("expected", end, "to be invalid")
+		}
+	}
+}
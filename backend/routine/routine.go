@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"github.com/denverquane/go-splitflap/display"
 	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/transition"
+	"reflect"
 	"time"
 )
 
 type Message struct {
 	Text     string
-	Duration time.Duration
+	Duration time.Duration // how long the routine expects to show the text before replacing it (0 if unknown)
 }
 
 type RoutineType string
@@ -24,6 +26,24 @@ type RoutineIface interface {
 	GetProviderName() string                                       // return the name of any provider that the routine requires to function properly ("" if none)
 }
 
+// RegionState is what the flaps in a routine's region physically show
+type RegionState struct {
+	Text    string // the character each module shows, in reading order ("" if the display hasn't reported its state yet)
+	Settled bool   // true once the display has reported its state and none of the region's modules are moving
+}
+
+// Cost estimates the flap travel of changing the region to text, before the display's translations are applied
+func (r RegionState) Cost(text string) transition.Motion {
+	return transition.EstimateMotion(r.Text, text)
+}
+
+// StatefulRoutine is optionally implemented by routines that react to the real flaps, e.g. to wait until the previous
+// text has landed before sending the next. Dashboards call UpdateWithState instead of Update for these routines
+type StatefulRoutine interface {
+	RoutineIface
+	UpdateWithState(now time.Time, values provider.ProviderValues, state RegionState) *Message
+}
+
 // Parameter represents configurable fields in a given Routine. If you write your own Routines, be meticulous
 // about specifying all required parameters and their expected types! This is how the web-ui knows what config it should
 // prompt the user to provide for a given routine
@@ -66,4 +86,16 @@ var AllRoutines = map[RoutineType]RoutineIface{
 	TEMPERATURE: &TemperatureRoutine{},
 	SEQUENCE:    &SequenceRoutine{},
 	DAYSUNTIL:   &DaysUntilRoutine{},
+	SLOWTEXT:    &SlowTextRoutine{},
+	VALUE:       &ValueRoutine{},
+}
+
+// NewRoutine returns a fresh, zero-valued routine of the given type. The instances in AllRoutines are shared, so they
+// should never be configured directly
+func NewRoutine(routineType RoutineType) (RoutineIface, bool) {
+	rout, ok := AllRoutines[routineType]
+	if !ok {
+		return nil, false
+	}
+	return reflect.New(reflect.ValueOf(rout).Elem().Type()).Interface().(RoutineIface), true
 }
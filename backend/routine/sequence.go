@@ -64,7 +64,8 @@ func (s *SequenceRoutine) Update(now time.Time, _ provider.ProviderValues) *Mess
 		s.idx = 0
 		s.lastUpdate = now
 		return &Message{
-			Text: display.LeftPad(s.Sequences[s.idx].Text, s.size),
+			Text:     display.LeftPad(s.Sequences[s.idx].Text, s.size),
+			Duration: time.Duration(s.Sequences[s.idx].Duration) * time.Millisecond,
 		}
 	}
 
@@ -87,7 +88,8 @@ func (s *SequenceRoutine) Update(now time.Time, _ provider.ProviderValues) *Mess
 
 	elem = s.Sequences[s.idx]
 	return &Message{
-		Text: display.LeftPad(elem.Text, s.size),
+		Text:     display.LeftPad(elem.Text, s.size),
+		Duration: time.Duration(elem.Duration) * time.Millisecond,
 	}
 }
 
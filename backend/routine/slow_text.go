@@ -1,177 +1,104 @@
 package routine
 
 import (
+	"errors"
 	"github.com/denverquane/go-splitflap/display"
-	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
-	"log"
-	"log/slog"
-	"math"
-	"sort"
+	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/transition"
 	"strings"
-	"sync"
 	"time"
 )
 
 const SLOWTEXT = "SLOWTEXT"
 
-type SlowText struct {
-	Text          string       `json:"text"`
-	Size_         display.Size `json:"size"`
-	LetterDelayMs int          `json:"letter_delay_ms"`
-	kill          chan struct{}
-	state         string
-	stateLock     sync.RWMutex
+// how long to wait for the region to report that it's blank before revealing the text anyway, e.g. if the display
+// isn't reporting its state
+const slowTextClearTimeout = time.Second * 10
+
+// SlowTextRoutine blanks its region, then reveals its text letter by letter: each module starts moving early enough
+// that the letters land one after another, LetterDelayMs apart
+type SlowTextRoutine struct {
+	Text          string `json:"text"`
+	LetterDelayMs int    `json:"letter_delay_ms"`
+
+	size    display.Size
+	target  []rune
+	starts  []time.Duration
+	cleared time.Time // when the region was sent blank text
+	started time.Time // when the reveal started
+	done    bool
 }
 
-func (s *SlowText) SizeRange() (display.Min, display.Max) {
+func (s *SlowTextRoutine) SizeRange() (display.Min, display.Max) {
 	return display.Min{Width: 1, Height: 1}, display.Max{Width: 100, Height: 100}
 }
 
-func (s *SlowText) Size() display.Size {
-	return s.Size_
-}
-
-func (s *SlowText) Check() error {
+func (s *SlowTextRoutine) Check() error {
+	if s.Text == "" {
+		return errors.New("text cannot be empty")
+	}
+	if s.LetterDelayMs < 0 {
+		return errors.New("letter delay cannot be negative")
+	}
 	return nil
 }
 
-const msPerFlap = 65
-
-type LetterStart struct {
-	char  rune
-	start int
-	pos   int
-}
-
-type LetterStarts []LetterStart
-
-func (s *SlowText) Start(queue chan<- Message) {
-	s.kill = make(chan struct{})
-	s.stateLock = sync.RWMutex{}
-
-	totalLen := s.Size_.Width * s.Size_.Height
-
-	charLen := len(s.Text)
-	idx := 0
-	empty := strings.Repeat(" ", totalLen)
-
-	letterStarts := createLetterStarts(s.Text, s.LetterDelayMs)
-	for _, v := range letterStarts {
-		slog.Info("letter", "char", string(v.char), "start", v.start, "pos", v.pos)
+func (s *SlowTextRoutine) Init(size display.Size) error {
+	if !supportsSize(s, size) {
+		return errors.New("routine does not support that size")
+	}
+	if len([]rune(s.Text)) > size.Width*size.Height {
+		return errors.New("text length exceeds defined routine size")
 	}
 
-	go func() {
-		queue <- Message{
-			Text: empty,
-		}
-
-		s.waitForEmptySequence(empty)
-		slog.Info("Empty, ready to begin")
-
-		offset := 0
-		for {
-			select {
-			case <-s.kill:
-				return
-			default:
-				if idx < charLen {
-					s.stateLock.RLock()
-					// continually send characters that are 1 less than the current state, so it never catches up
-					newState := spinLetters(letterStarts, idx+1, []rune(s.state))
-					s.stateLock.RUnlock()
-
-					log.Println(newState)
-					queue <- Message{
-						Text: newState,
-					}
-					delay := 0
-					if idx < charLen-1 {
-						delay = letterStarts[idx+1].start - offset
-						offset += delay
-					}
-					slog.Info("sleeping", "duration", delay)
-					time.Sleep(time.Millisecond * time.Duration(delay))
-					idx++
-				} else {
-					// TODO spin for a bit before settling the characters finally
-					time.Sleep(time.Millisecond * 1000)
-					queue <- Message{
-						Text: s.Text,
-					}
-					// do we want to exit entirely?
-					return
-				}
-			}
-		}
-	}()
+	s.size = size
+	s.target = []rune(display.RightPad(s.Text, size))
+	s.starts = nil
+	s.cleared = time.Time{}
+	s.started = time.Time{}
+	s.done = false
+	return nil
 }
 
-func (s *SlowText) waitForEmptySequence(empty string) {
-	for {
-		s.stateLock.RLock()
-		checkState := s.state
-		s.stateLock.RUnlock()
+// Update can't see the flaps, so the region is given slowTextClearTimeout to clear before the text is revealed
+func (s *SlowTextRoutine) Update(now time.Time, values provider.ProviderValues) *Message {
+	return s.UpdateWithState(now, values, RegionState{})
+}
 
-		if checkState == empty {
-			return
-		} else {
-			time.Sleep(time.Millisecond * 100)
-		}
+func (s *SlowTextRoutine) UpdateWithState(now time.Time, _ provider.ProviderValues, state RegionState) *Message {
+	if s.done {
+		return nil
 	}
-}
+	empty := strings.Repeat(" ", len(s.target))
 
-func createLetterStarts(text string, delayMs int) LetterStarts {
-	startTimes := make(LetterStarts, len(text))
-	minimum := 0
-	for i := range startTimes {
-		aIdx := usb_serial.AlphabetIndex(rune(text[i]))
-		if aIdx == 0 {
-			aIdx = len(usb_serial.GlobalAlphabet) - 1
-		}
-		v := i*delayMs - aIdx*msPerFlap
-		if v < minimum {
-			minimum = v
-		}
-		startTimes[i] = LetterStart{
-			char:  rune(text[i]),
-			start: v,
-			pos:   i,
-		}
+	// blank the region first, so that every letter travels from the same place
+	if s.cleared.IsZero() {
+		s.cleared = now
+		return &Message{Text: empty}
 	}
-	for i, v := range startTimes {
-		v.start += int(math.Abs(float64(minimum)))
-		startTimes[i] = v
+	if s.started.IsZero() {
+		if (state.Text != empty || !state.Settled) && now.Sub(s.cleared) < slowTextClearTimeout {
+			return nil
+		}
+		s.started = now
+		s.starts = transition.StaggerStarts([]rune(empty), s.target, time.Duration(s.LetterDelayMs)*time.Millisecond)
 	}
-	sort.Slice(startTimes, func(i, j int) bool {
-		return startTimes[i].start < startTimes[j].start
-	})
-	return startTimes
-}
 
-func spinLetters(letters LetterStarts, idx int, state []rune) string {
-	for i := 0; i < idx; i++ {
-		letterStart := letters[i]
-		charIdx := usb_serial.AlphabetIndex(state[letterStart.pos])
-		if charIdx == 0 {
-			charIdx = len(usb_serial.GlobalAlphabet)
+	// every letter whose start time has passed is sent on its way; the rest stay blank
+	elapsed := now.Sub(s.started)
+	text := []rune(empty)
+	s.done = true
+	for i, start := range s.starts {
+		if elapsed >= start {
+			text[i] = s.target[i]
+		} else {
+			s.done = false
 		}
-		state[letterStart.pos] = usb_serial.GlobalAlphabet[charIdx-1]
 	}
-	return string(state)
-}
-
-func (s *SlowText) SetState(state string) {
-	s.stateLock.Lock()
-	s.state = state
-	slog.Info("Slow text received state: " + s.state)
-	s.stateLock.Unlock()
+	return &Message{Text: string(text)}
 }
 
-func (s *SlowText) Stop() {
-	s.kill <- struct{}{}
-}
-
-func (s *SlowText) Parameters() []Parameter {
+func (s *SlowTextRoutine) Parameters() []Parameter {
 	return []Parameter{
 		{
 			Name:        "Text",
@@ -187,3 +114,7 @@ func (s *SlowText) Parameters() []Parameter {
 		},
 	}
 }
+
+func (s *SlowTextRoutine) GetProviderName() string {
+	return ""
+}
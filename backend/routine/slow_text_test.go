@@ -0,0 +1,25 @@
+package routine
+
+import (
+	"testing"
+
+	"github.com/denverquane/go-splitflap/display"
+)
+
+func TestSlowTextRoutine_Init_multibyte(t *testing.T) {
+	s := SlowTextRoutine{Text: "21°C"}
+	if err := s.Init(display.Size{Width: 4, Height: 1}); err != nil {
+		t.Fatal("text that fills the routine should fit, however many bytes its flaps take", err)
+	}
+	if err := s.Init(display.Size{Width: 3, Height: 1}); err == nil {
+		t.Fatal("text longer than the routine shouldn't fit")
+	}
+
+	s = SlowTextRoutine{Text: "°C"}
+	if err := s.Init(display.Size{Width: 4, Height: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.target) != 4 {
+		t.Fatal("text should be padded out to one flap per module, got", string(s.target))
+	}
+}
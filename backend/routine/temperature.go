@@ -51,8 +51,12 @@ func (w *TemperatureRoutine) Update(now time.Time, values provider.ProviderValue
 	}
 
 	if weatherVals, ok := values[w.ProviderName]; ok {
-		units := weatherVals["units"].(string)
-		temp := weatherVals[w.ProviderValue].(float64)
+		// a provider that doesn't supply a number yet (or at all) is skipped, rather than trusted
+		temp, ok := weatherVals[w.ProviderValue].(float64)
+		if !ok {
+			return nil
+		}
+		units, _ := weatherVals["units"].(string)
 
 		msg := Message{
 			Text: display.LeftPad(w.formatTemp(temp, units), w.size),
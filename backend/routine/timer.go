@@ -45,7 +45,7 @@ func (t *TimerRoutine) Update(now time.Time, _ provider.ProviderValues) *Message
 		return nil
 	}
 
-	msg := Message{}
+	msg := Message{Duration: time.Second}
 	if now.After(t.End) {
 		msg.Text = strings.Repeat("g", t.size.Width)
 	} else {
@@ -0,0 +1,304 @@
+package routine
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/provider"
+)
+
+const VALUE = "VALUE"
+
+// placeholders in a format look like {value}, or {name} for any other value of the same provider
+var valuePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValueRoutine shows any value of a provider through a format template, so a new provider can be shown without
+// writing a routine for it. It falls back to fixed text whenever the value can't be shown as configured
+type ValueRoutine struct {
+	ProviderName  string           `json:"provider_name"`
+	ProviderValue string           `json:"provider_value"`
+	Format        string           `json:"format"`   // e.g. "{value}°{units}". Just the value if empty
+	Decimals      *int             `json:"decimals"` // how many decimals numbers are shown with. As many as needed if not set
+	Thresholds    []ValueThreshold `json:"thresholds"`
+	Fallback      string           `json:"fallback"`   // shown when the value is missing, stale, or doesn't fit
+	StaleSecs     int              `json:"stale_secs"` // how old the provider's values can get before they are stale. 0 for no limit
+	Align         string           `json:"align"`      // left, right or center. Numbers are right aligned and text left aligned if not set
+
+	size       display.Size
+	lastUpdate time.Time
+	shown      string
+	hasShown   bool
+}
+
+// ValueThreshold swaps in a different format once a number reaches a value, e.g. "HOT {value}" at 30 or more
+type ValueThreshold struct {
+	AtLeast float64 `json:"at_least"`
+	Format  string  `json:"format"`
+}
+
+func (v *ValueRoutine) SizeRange() (display.Min, display.Max) {
+	return display.Min{Width: 1, Height: 1}, display.Max{Width: 100, Height: 1}
+}
+
+func (v *ValueRoutine) Check() error {
+	if v.ProviderName == "" {
+		return errors.New("field config.provider_name: must be set")
+	}
+	if v.ProviderValue == "" {
+		return errors.New("field config.provider_value: must be set")
+	}
+	if err := checkValueFormat(v.Format); err != nil {
+		return errors.New("field config.format: " + err.Error())
+	}
+	if v.Decimals != nil && (*v.Decimals < 0 || *v.Decimals > 10) {
+		return errors.New("field config.decimals: must be between 0 and 10")
+	}
+	for i, threshold := range v.Thresholds {
+		if err := checkValueFormat(threshold.Format); err != nil {
+			return errors.New("field config.thresholds." + strconv.Itoa(i) + ".format: " + err.Error())
+		}
+	}
+	if v.StaleSecs < 0 {
+		return errors.New("field config.stale_secs: cannot be negative")
+	}
+	switch v.Align {
+	case "", "left", "right", "center":
+	default:
+		return errors.New("field config.align: must be left, right, center or empty")
+	}
+	return nil
+}
+
+// checkValueFormat makes sure every { in a format starts a placeholder with a name, and every } ends one
+func checkValueFormat(format string) error {
+	for _, match := range valuePlaceholder.FindAllStringSubmatch(format, -1) {
+		if strings.TrimSpace(match[1]) == "" {
+			return errors.New("placeholders must name a value, like {value}")
+		}
+	}
+	if strings.ContainsAny(valuePlaceholder.ReplaceAllString(format, ""), "{}") {
+		return errors.New("unbalanced { or }")
+	}
+	return nil
+}
+
+func (v *ValueRoutine) Init(size display.Size) error {
+	if !supportsSize(v, size) {
+		return errors.New("routine does not support that size")
+	}
+	if len([]rune(v.Fallback)) > size.Width*size.Height {
+		return errors.New("fallback text exceeds defined routine size")
+	}
+
+	v.size = size
+	// set the last update to 0 so that the first call to Update always renders text
+	v.lastUpdate = time.Time{}
+	v.hasShown = false
+	return nil
+}
+
+func (v *ValueRoutine) Update(now time.Time, values provider.ProviderValues) *Message {
+	if now.Sub(v.lastUpdate) < time.Second {
+		return nil
+	}
+	v.lastUpdate = now
+
+	text := v.render(now, values[v.ProviderName])
+	if v.hasShown && text == v.shown {
+		return nil
+	}
+	v.shown = text
+	v.hasShown = true
+	return &Message{Text: text}
+}
+
+// render formats the routine's value out of the values its provider supplies, aligned to fill the routine's width. It
+// never fails: anything that can't be shown as configured is shown as the fallback text instead
+func (v *ValueRoutine) render(now time.Time, values provider.PValues) string {
+	width := v.size.Width * v.size.Height
+
+	value, ok := values[v.ProviderValue]
+	if !ok || v.stale(now, values) {
+		return v.align(v.Fallback, false, width)
+	}
+	number, isNumber := toFloat(value)
+
+	format := v.Format
+	if format == "" {
+		format = "{value}"
+	}
+	reached := false
+	var atLeast float64
+	for _, threshold := range v.Thresholds {
+		if isNumber && number >= threshold.AtLeast && (!reached || threshold.AtLeast >= atLeast) {
+			format, atLeast, reached = threshold.Format, threshold.AtLeast, true
+		}
+	}
+
+	text, ok := v.expand(format, value, values)
+	if !ok || len([]rune(text)) > width {
+		return v.align(v.Fallback, false, width)
+	}
+	return v.align(text, isNumber, width)
+}
+
+// stale is true if the provider knows when it last fetched its values, and either it never has or it was too long ago
+func (v *ValueRoutine) stale(now time.Time, values provider.PValues) bool {
+	updated, ok := values[provider.UpdatedValue].(time.Time)
+	if !ok {
+		return false
+	}
+	if updated.IsZero() {
+		return true
+	}
+	return v.StaleSecs > 0 && now.Sub(updated) > time.Duration(v.StaleSecs)*time.Second
+}
+
+// expand fills in the placeholders of a format. It fails if any of them names a value that is missing, or can't be
+// shown as text
+func (v *ValueRoutine) expand(format string, value any, values provider.PValues) (string, bool) {
+	ok := true
+	text := valuePlaceholder.ReplaceAllStringFunc(format, func(placeholder string) string {
+		name := strings.TrimSpace(placeholder[1 : len(placeholder)-1])
+		val := value
+		if name != "value" {
+			var found bool
+			if val, found = values[name]; !found {
+				ok = false
+				return ""
+			}
+		}
+		str, formatted := v.formatValue(val)
+		ok = ok && formatted
+		return str
+	})
+	return text, ok
+}
+
+// formatValue turns a single value into text, with the configured number of decimals if it is a number
+func (v *ValueRoutine) formatValue(value any) (string, bool) {
+	if number, ok := toFloat(value); ok {
+		decimals := -1
+		if v.Decimals != nil {
+			decimals = *v.Decimals
+		}
+		return strconv.FormatFloat(number, 'f', decimals, 64), true
+	}
+	switch val := value.(type) {
+	case string:
+		return val, true
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(val)), true
+	}
+	return "", false
+}
+
+// toFloat converts any of the number types a provider might supply to a float64
+func toFloat(value any) (float64, bool) {
+	switch val := value.(type) {
+	case float64:
+		return val, true
+	case float32:
+		return float64(val), true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case int32:
+		return float64(val), true
+	case uint:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case uint32:
+		return float64(val), true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// align pads text out to the width of the routine
+func (v *ValueRoutine) align(text string, isNumber bool, width int) string {
+	gap := width - len([]rune(text))
+	if gap <= 0 {
+		return text
+	}
+	align := v.Align
+	if align == "" {
+		align = "left"
+		if isNumber {
+			align = "right"
+		}
+	}
+	switch align {
+	case "right":
+		return strings.Repeat(" ", gap) + text
+	case "center":
+		return strings.Repeat(" ", gap/2) + text + strings.Repeat(" ", gap-gap/2)
+	}
+	return text + strings.Repeat(" ", gap)
+}
+
+func (v *ValueRoutine) Parameters() []Parameter {
+	return []Parameter{
+		{
+			Name:        "Provider Name",
+			Description: "The name of the provider to subscribe to",
+			Field:       "provider_name",
+			Type:        "string",
+		},
+		{
+			Name:        "Provider Value",
+			Description: "The name of the value that the provider populates, that this routine should then show",
+			Field:       "provider_value",
+			Type:        "string",
+		},
+		{
+			Name:        "Format",
+			Description: "How to show the value, e.g. \"{value}°{units}\". {value} is the value, and {name} is any other value of the provider. Just the value if empty",
+			Field:       "format",
+			Type:        "string",
+		},
+		{
+			Name:        "Decimals",
+			Description: "How many decimals to show numbers with. As many as needed if not set",
+			Field:       "decimals",
+			Type:        "int",
+		},
+		{
+			Name:        "Thresholds",
+			Description: "Formats to use instead once a number reaches a value, e.g. [{\"at_least\": 30, \"format\": \"HOT {value}\"}]. The highest threshold reached is used",
+			Field:       "thresholds",
+			Type:        "[{\"at_least\": float, \"format\": string}]",
+		},
+		{
+			Name:        "Fallback",
+			Description: "The text to show when the value is missing, stale, not something that can be shown, or too long to fit",
+			Field:       "fallback",
+			Type:        "string",
+		},
+		{
+			Name:        "Stale Seconds",
+			Description: "How old the provider's values can get before the fallback is shown instead, for providers that report when they last updated. 0 for no limit",
+			Field:       "stale_secs",
+			Type:        "int",
+		},
+		{
+			Name:        "Align",
+			Description: "left, right or center. Numbers are right aligned and text left aligned if not set",
+			Field:       "align",
+			Type:        "string",
+		},
+	}
+}
+
+func (v *ValueRoutine) GetProviderName() string {
+	return v.ProviderName
+}
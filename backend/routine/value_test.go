@@ -0,0 +1,65 @@
+package routine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/provider"
+)
+
+func TestValueRoutine_render(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	one := 1
+	zero := 0
+	thresholds := []ValueThreshold{{AtLeast: 30, Format: "HOT{value}"}, {AtLeast: -100, Format: "{value}"}, {AtLeast: 40, Format: "HOT!"}}
+
+	for _, test := range []struct {
+		name   string
+		config ValueRoutine
+		values provider.PValues
+		text   string
+	}{
+		{"number", ValueRoutine{}, provider.PValues{"v": 21.5}, "  21.5"},
+		{"decimals and units", ValueRoutine{Decimals: &one, Format: "{value}°{units}"}, provider.PValues{"v": 21.04, "units": "C"}, "21.0°C"},
+		{"text", ValueRoutine{}, provider.PValues{"v": "OPEN"}, "OPEN  "},
+		{"centered", ValueRoutine{Align: "center"}, provider.PValues{"v": "ON"}, "  ON  "},
+		{"bool", ValueRoutine{}, provider.PValues{"v": true}, "TRUE  "},
+		{"threshold", ValueRoutine{Thresholds: thresholds, Decimals: &zero}, provider.PValues{"v": 31.6}, " HOT32"},
+		{"highest threshold", ValueRoutine{Thresholds: thresholds}, provider.PValues{"v": 45}, "  HOT!"},
+		{"missing", ValueRoutine{Fallback: "--"}, provider.PValues{}, "--    "},
+		{"missing from format", ValueRoutine{Fallback: "--", Format: "{value}{units}"}, provider.PValues{"v": 1}, "--    "},
+		{"wrong type", ValueRoutine{Fallback: "--"}, provider.PValues{"v": []string{"a"}}, "--    "},
+		{"too long", ValueRoutine{Fallback: "--"}, provider.PValues{"v": 1234567.0}, "--    "},
+		{"never updated", ValueRoutine{Fallback: "--"}, provider.PValues{"v": 0.0, provider.UpdatedValue: time.Time{}}, "--    "},
+		{"stale", ValueRoutine{Fallback: "--", StaleSecs: 60}, provider.PValues{"v": 3, provider.UpdatedValue: now.Add(-time.Hour)}, "--    "},
+		{"fresh", ValueRoutine{Fallback: "--", StaleSecs: 60}, provider.PValues{"v": 3, provider.UpdatedValue: now.Add(-time.Second)}, "     3"},
+	} {
+		config := test.config
+		config.ProviderName, config.ProviderValue = "p", "v"
+		if err := config.Check(); err != nil {
+			t.Fatal(test.name, err)
+		}
+		if err := config.Init(display.Size{Width: 6, Height: 1}); err != nil {
+			t.Fatal(test.name, err)
+		}
+		msg := config.Update(now, provider.ProviderValues{"p": test.values})
+		if msg == nil || msg.Text != test.text {
+			t.Errorf("%s: expected %q, got %v", test.name, test.text, msg)
+		}
+	}
+}
+
+func TestValueRoutine_Check(t *testing.T) {
+	for _, config := range []ValueRoutine{
+		{ProviderValue: "v"},
+		{ProviderName: "p", ProviderValue: "v", Format: "{value"},
+		{ProviderName: "p", ProviderValue: "v", Format: "{}"},
+		{ProviderName: "p", ProviderValue: "v", Thresholds: []ValueThreshold{{Format: "}"}}},
+		{ProviderName: "p", ProviderValue: "v", Align: "justify"},
+	} {
+		if err := config.Check(); err == nil {
+			t.Errorf("expected %+v to be invalid", config)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package usb_serial
+
+import (
+	"errors"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+// ResetAndHome makes a single module forget its position and search for its home sensor again
+func (sf *Splitflap) ResetAndHome(idx int) error {
+	return sf.sendModuleCommand(idx, gen.SplitflapCommand_ModuleCommand_RESET_AND_HOME, 0)
+}
+
+// ResetAndHomeAll resets and homes every module at once
+func (sf *Splitflap) ResetAndHomeAll() error {
+	return sf.sendCommand(func(modules []*gen.SplitflapCommand_ModuleCommand) error {
+		for _, m := range modules {
+			m.Action = gen.SplitflapCommand_ModuleCommand_RESET_AND_HOME
+		}
+		return nil
+	}, false)
+}
+
+// IncreaseOffsetTenth nudges a module's home offset forward by a tenth of a flap
+func (sf *Splitflap) IncreaseOffsetTenth(idx int) error {
+	return sf.sendModuleCommand(idx, gen.SplitflapCommand_ModuleCommand_INCREASE_OFFSET_TENTH, 0)
+}
+
+// IncreaseOffsetHalf nudges a module's home offset forward by half a flap
+func (sf *Splitflap) IncreaseOffsetHalf(idx int) error {
+	return sf.sendModuleCommand(idx, gen.SplitflapCommand_ModuleCommand_INCREASE_OFFSET_HALF, 0)
+}
+
+// SetOffset tells a module that the flap it is currently showing is the flap at flapIndex, adjusting its offset
+func (sf *Splitflap) SetOffset(idx int, flapIndex uint32) error {
+	return sf.sendModuleCommand(idx, gen.SplitflapCommand_ModuleCommand_SET_OFFSET, flapIndex)
+}
+
+// GoToFlap moves a single module to a flap, without changing what the rest of the display is configured to show
+func (sf *Splitflap) GoToFlap(idx int, flapIndex uint32) error {
+	return sf.sendModuleCommand(idx, gen.SplitflapCommand_ModuleCommand_GO_TO_FLAP, flapIndex)
+}
+
+// SaveAllOffsets persists the current offsets of every module to the controller's flash
+func (sf *Splitflap) SaveAllOffsets() error {
+	return sf.sendCommand(func(_ []*gen.SplitflapCommand_ModuleCommand) error {
+		return nil
+	}, true)
+}
+
+func (sf *Splitflap) sendModuleCommand(idx int, action gen.SplitflapCommand_ModuleCommand_Action, param uint32) error {
+	return sf.sendCommand(func(modules []*gen.SplitflapCommand_ModuleCommand) error {
+		if idx < 0 || idx >= len(modules) {
+			return errors.New("module index out of range")
+		}
+		modules[idx].Action = action
+		modules[idx].Param = param
+		return nil
+	}, false)
+}
+
+// sendCommand builds a command with a NO_OP for every module, lets the caller fill in the actions, and enqueues it
+func (sf *Splitflap) sendCommand(setActions func(modules []*gen.SplitflapCommand_ModuleCommand) error, saveAllOffsets bool) error {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	if sf.numModules == 0 {
+		return errors.New("cannot send commands before the number of modules is known")
+	}
+	if !sf.Connected() {
+		return errors.New("splitflap is not connected")
+	}
+	if !saveAllOffsets && sf.supervisorFault() {
+		return errors.New("not moving modules while the power supervisor reports a fault")
+	}
+
+	modules := make([]*gen.SplitflapCommand_ModuleCommand, sf.numModules)
+	for i := range modules {
+		modules[i] = &gen.SplitflapCommand_ModuleCommand{
+			Action: gen.SplitflapCommand_ModuleCommand_NO_OP,
+		}
+	}
+	if err := setActions(modules); err != nil {
+		return err
+	}
+
+	sf.enqueueMessage(&gen.ToSplitflap{
+		Payload: &gen.ToSplitflap_SplitflapCommand{
+			SplitflapCommand: &gen.SplitflapCommand{
+				Modules:        modules,
+				SaveAllOffsets: saveAllOffsets,
+			},
+		},
+	})
+	return nil
+}
+
+// NumModules returns how many modules the splitflap has (0 if not yet known)
+func (sf *Splitflap) NumModules() int {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+	return sf.numModules
+}
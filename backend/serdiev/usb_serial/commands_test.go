@@ -0,0 +1,63 @@
+package usb_serial
+
+import (
+	"testing"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+func dequeueCommand(t *testing.T, sf *Splitflap) *gen.SplitflapCommand {
+	msg, ok := sf.outQueue.pop()
+	if !ok {
+		t.Fatal("expected a message to be enqueued")
+	}
+	payload, ok := utils.ParseCRC32EncodedPayload(msg.bytes[:len(msg.bytes)-1])
+	if !ok {
+		t.Fatal("enqueued message has an invalid checksum")
+	}
+	decoded := &gen.ToSplitflap{}
+	if err := proto.Unmarshal(payload, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.GetSplitflapCommand() == nil {
+		t.Fatal("expected a splitflap command to be enqueued")
+	}
+	return decoded.GetSplitflapCommand()
+}
+
+func TestSplitflap_SetOffset(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(3, DefaultSimulatorConfig), nil, 3)
+	if err := sf.SetOffset(1, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := dequeueCommand(t, sf)
+	if len(cmd.Modules) != 3 {
+		t.Fatal("command should contain an entry for every module")
+	}
+	if cmd.Modules[0].Action != gen.SplitflapCommand_ModuleCommand_NO_OP || cmd.Modules[2].Action != gen.SplitflapCommand_ModuleCommand_NO_OP {
+		t.Fatal("modules that weren't targeted should receive NO_OP")
+	}
+	if cmd.Modules[1].Action != gen.SplitflapCommand_ModuleCommand_SET_OFFSET || cmd.Modules[1].Param != 7 {
+		t.Fatal("targeted module should receive SET_OFFSET with its param")
+	}
+}
+
+func TestSplitflap_SaveAllOffsets(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(3, DefaultSimulatorConfig), nil, 3)
+	if err := sf.SaveAllOffsets(); err != nil {
+		t.Fatal(err)
+	}
+	if cmd := dequeueCommand(t, sf); !cmd.SaveAllOffsets {
+		t.Fatal("expected save_all_offsets to be set")
+	}
+}
+
+func TestSplitflap_moduleCommandOutOfRange(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(3, DefaultSimulatorConfig), nil, 3)
+	if sf.ResetAndHome(3) == nil {
+		t.Fatal("expected an error for an out of range module")
+	}
+}
@@ -0,0 +1,112 @@
+package usb_serial
+
+import (
+	"log/slog"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+const (
+	minReconnectBackoff = time.Millisecond * 500
+	maxReconnectBackoff = time.Second * 30
+)
+
+// ConnectionStatus describes whether the backend can currently talk to the splitflap hardware
+type ConnectionStatus struct {
+	Connected         bool      `json:"connected"`
+	Since             time.Time `json:"since"` // when the connection was (re)established, or when it was lost
+	ReconnectAttempts int       `json:"reconnect_attempts"`
+	LastError         string    `json:"last_error"`
+}
+
+func (sf *Splitflap) Status() ConnectionStatus {
+	sf.statusLock.RLock()
+	defer sf.statusLock.RUnlock()
+	return sf.status
+}
+
+// SetStatusHandler registers a function that is called whenever the connection status changes
+func (sf *Splitflap) SetStatusHandler(handler func(status ConnectionStatus)) {
+	sf.statusLock.Lock()
+	defer sf.statusLock.Unlock()
+	sf.onStatusChange = handler
+}
+
+func (sf *Splitflap) Connected() bool {
+	return sf.Status().Connected
+}
+
+func (sf *Splitflap) updateStatus(update func(status *ConnectionStatus)) {
+	sf.statusLock.Lock()
+	update(&sf.status)
+	status := sf.status
+	handler := sf.onStatusChange
+	sf.statusLock.Unlock()
+
+	if handler != nil {
+		handler(status)
+	}
+}
+
+// reconnect is called when the serial connection fails. It blocks, retrying with exponential backoff, until the
+// connection is re-established (or the Splitflap is stopped), then restores the state of the hardware
+func (sf *Splitflap) reconnect(cause error) bool {
+	slog.Error("Lost connection to splitflap, reconnecting", "error", cause)
+	sf.updateStatus(func(status *ConnectionStatus) {
+		status.Connected = false
+		status.Since = time.Now()
+		status.ReconnectAttempts = 0
+		status.LastError = cause.Error()
+	})
+	sf.serial.Close()
+
+	backoff := minReconnectBackoff
+	for {
+		select {
+		case <-sf.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		err := sf.serial.Reopen()
+		if err == nil {
+			break
+		}
+		sf.updateStatus(func(status *ConnectionStatus) {
+			status.ReconnectAttempts++
+			status.LastError = err.Error()
+		})
+		slog.Info("Failed to reconnect to splitflap", "error", err, "retry_in", backoff)
+
+		backoff *= 2
+		if backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+	slog.Info("Reconnected to splitflap")
+	sf.updateStatus(func(status *ConnectionStatus) {
+		status.Connected = true
+		status.Since = time.Now()
+		status.LastError = ""
+	})
+
+	sf.RequestState()
+	sf.resendConfig()
+	return true
+}
+
+// resendConfig sends the last known config again, so the hardware shows what it was showing before it went away
+func (sf *Splitflap) resendConfig() {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	if sf.currentConfig == nil {
+		return
+	}
+	sf.enqueueMessage(&gen.ToSplitflap{
+		Payload: &gen.ToSplitflap_SplitflapConfig{
+			SplitflapConfig: sf.currentConfig,
+		},
+	})
+}
@@ -0,0 +1,114 @@
+package usb_serial
+
+import (
+	"log/slog"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+// healthHistoryLength is how many supervisor reports are kept in memory
+const healthHistoryLength = 300
+
+type PowerChannel struct {
+	VoltageVolts float32 `json:"voltage_volts"`
+	CurrentAmps  float32 `json:"current_amps"`
+	On           bool    `json:"on"`
+}
+
+// Health is a snapshot of the power supervisor's state, as reported by the hardware
+type Health struct {
+	State         string         `json:"state"`
+	UptimeMillis  uint32         `json:"uptime_ms"`
+	PowerChannels []PowerChannel `json:"power_channels"`
+	FaultType     string         `json:"fault_type"`
+	FaultMessage  string         `json:"fault_message"`
+	Received      time.Time      `json:"received"`
+}
+
+func (h Health) Faulted() bool {
+	return h.State == gen.SupervisorState_FAULT.String()
+}
+
+func healthFromSupervisorState(state *gen.SupervisorState, now time.Time) Health {
+	h := Health{
+		State:         state.GetState().String(),
+		UptimeMillis:  state.GetUptimeMillis(),
+		PowerChannels: make([]PowerChannel, 0, len(state.GetPowerChannels())),
+		Received:      now,
+	}
+	for _, ch := range state.GetPowerChannels() {
+		h.PowerChannels = append(h.PowerChannels, PowerChannel{
+			VoltageVolts: ch.GetVoltageVolts(),
+			CurrentAmps:  ch.GetCurrentAmps(),
+			On:           ch.GetOn(),
+		})
+	}
+	if fault := state.GetFaultInfo(); fault != nil {
+		h.FaultType = fault.GetType().String()
+		h.FaultMessage = fault.GetMsg()
+	}
+	return h
+}
+
+// Health returns the most recent supervisor report, and false if the hardware hasn't sent one
+func (sf *Splitflap) Health() (Health, bool) {
+	sf.healthLock.RLock()
+	defer sf.healthLock.RUnlock()
+
+	if len(sf.healthHistory) == 0 {
+		return Health{}, false
+	}
+	return sf.healthHistory[len(sf.healthHistory)-1], true
+}
+
+// HealthHistory returns the most recent supervisor reports, oldest first
+func (sf *Splitflap) HealthHistory() []Health {
+	sf.healthLock.RLock()
+	defer sf.healthLock.RUnlock()
+
+	history := make([]Health, len(sf.healthHistory))
+	copy(history, sf.healthHistory)
+	return history
+}
+
+// SetHealthHandler registers a function that is called whenever the supervisor state or fault changes
+func (sf *Splitflap) SetHealthHandler(handler func(health Health)) {
+	sf.healthLock.Lock()
+	defer sf.healthLock.Unlock()
+	sf.onHealthChange = handler
+}
+
+func (sf *Splitflap) supervisorFault() bool {
+	health, ok := sf.Health()
+	return ok && health.Faulted()
+}
+
+func (sf *Splitflap) recordHealth(state *gen.SupervisorState) {
+	health := healthFromSupervisorState(state, time.Now())
+
+	sf.healthLock.Lock()
+	changed := true
+	if len(sf.healthHistory) > 0 {
+		prev := sf.healthHistory[len(sf.healthHistory)-1]
+		changed = prev.State != health.State || prev.FaultType != health.FaultType
+	}
+	sf.healthHistory = append(sf.healthHistory, health)
+	if len(sf.healthHistory) > healthHistoryLength {
+		sf.healthHistory = sf.healthHistory[len(sf.healthHistory)-healthHistoryLength:]
+	}
+	handler := sf.onHealthChange
+	sf.healthLock.Unlock()
+
+	if !changed {
+		return
+	}
+	if health.Faulted() {
+		slog.Error("Splitflap supervisor reported a fault, no longer sending movement commands", "fault", health.FaultType, "message", health.FaultMessage)
+	} else {
+		slog.Info("Splitflap supervisor state changed", "state", health.State)
+	}
+	if handler != nil {
+		handler(health)
+	}
+}
@@ -0,0 +1,73 @@
+package usb_serial
+
+import (
+	"testing"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+func supervisorState(state gen.SupervisorState_State) *gen.SupervisorState {
+	return &gen.SupervisorState{
+		UptimeMillis: 1000,
+		State:        state,
+		PowerChannels: []*gen.SupervisorState_PowerChannelState{
+			{VoltageVolts: 12.1, CurrentAmps: 0.5, On: true},
+		},
+	}
+}
+
+func TestSplitflap_recordHealth(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	changes := 0
+	sf.SetHealthHandler(func(health Health) {
+		changes++
+	})
+
+	sf.recordHealth(supervisorState(gen.SupervisorState_NORMAL))
+	sf.recordHealth(supervisorState(gen.SupervisorState_NORMAL))
+
+	health, ok := sf.Health()
+	if !ok || health.State != "NORMAL" || len(health.PowerChannels) != 1 || health.PowerChannels[0].VoltageVolts != 12.1 {
+		t.Fatal("unexpected health", health)
+	}
+	if len(sf.HealthHistory()) != 2 {
+		t.Fatal("expected both reports to be kept in the history")
+	}
+	if changes != 1 {
+		t.Fatal("handler should only be called when the supervisor state changes")
+	}
+}
+
+func TestSplitflap_faultStopsMovement(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	GlobalAlphabet = []rune(" AB")
+
+	faulted := supervisorState(gen.SupervisorState_FAULT)
+	faulted.FaultInfo = &gen.SupervisorState_FaultInfo{Type: gen.SupervisorState_FaultInfo_OVER_CURRENT}
+	sf.recordHealth(faulted)
+
+	if health, _ := sf.Health(); health.FaultType != "OVER_CURRENT" {
+		t.Fatal("expected fault type to be recorded")
+	}
+	if sf.SetText("AB") == nil {
+		t.Fatal("expected movement to be refused while faulted")
+	}
+	if sf.ResetAndHome(0) == nil {
+		t.Fatal("expected module commands to be refused while faulted")
+	}
+
+	sf.recordHealth(supervisorState(gen.SupervisorState_NORMAL))
+	if err := sf.SetText("AB"); err != nil {
+		t.Fatal("expected movement to resume once the fault clears", err)
+	}
+}
+
+func TestSplitflap_healthHistoryLength(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	for range healthHistoryLength + 10 {
+		sf.recordHealth(supervisorState(gen.SupervisorState_NORMAL))
+	}
+	if len(sf.HealthHistory()) != healthHistoryLength {
+		t.Fatal("health history should be capped")
+	}
+}
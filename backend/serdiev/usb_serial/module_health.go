@@ -0,0 +1,191 @@
+package usb_serial
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+// moduleTransitionHistory is how many state transitions are kept for each module
+const moduleTransitionHistory = 20
+
+// AutoHomeConfig controls when a module is automatically sent RESET_AND_HOME because it appears to be struggling
+type AutoHomeConfig struct {
+	Enabled                 bool `json:"enabled"`
+	ErrorThreshold          int  `json:"error_threshold"`           // entries into SENSOR_ERROR, PANIC or STATE_DISABLED
+	MissedHomeThreshold     int  `json:"missed_home_threshold"`     // increase in the module's missed home count
+	UnexpectedHomeThreshold int  `json:"unexpected_home_threshold"` // increase in the module's unexpected home count
+	CooldownSecs            int  `json:"cooldown_secs"`             // minimum time between automatic homes of the same module
+}
+
+var DefaultAutoHomeConfig = AutoHomeConfig{
+	Enabled:                 true,
+	ErrorThreshold:          3,
+	MissedHomeThreshold:     5,
+	UnexpectedHomeThreshold: 5,
+	CooldownSecs:            60,
+}
+
+func (c AutoHomeConfig) Check() error {
+	if c.ErrorThreshold < 1 || c.MissedHomeThreshold < 1 || c.UnexpectedHomeThreshold < 1 {
+		return errors.New("auto home thresholds must be at least 1")
+	}
+	if c.CooldownSecs < 0 {
+		return errors.New("auto home cooldown cannot be negative")
+	}
+	return nil
+}
+
+type ModuleTransition struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// ModuleHealth is the tracked history of a single physical module
+type ModuleHealth struct {
+	Index               int                `json:"index"`
+	State               string             `json:"state"`
+	ErrorCount          int                `json:"error_count"`
+	LastError           time.Time          `json:"last_error"`
+	LastErrorState      string             `json:"last_error_state"`
+	CountMissedHome     uint32             `json:"count_missed_home"`
+	CountUnexpectedHome uint32             `json:"count_unexpected_home"`
+	Transitions         []ModuleTransition `json:"transitions"`
+	AutoHomes           int                `json:"auto_homes"`
+	LastAutoHome        time.Time          `json:"last_auto_home"`
+
+	// counters as of the last time the module was homed, so thresholds apply to what happened since then
+	errorsSinceHome          int
+	missedHomeAtLastHome     uint32
+	unexpectedHomeAtLastHome uint32
+}
+
+func isModuleErrorState(state gen.SplitflapState_ModuleState_State) bool {
+	return state == gen.SplitflapState_ModuleState_SENSOR_ERROR ||
+		state == gen.SplitflapState_ModuleState_PANIC ||
+		state == gen.SplitflapState_ModuleState_STATE_DISABLED
+}
+
+// ModuleHealth returns the tracked health of every module, in physical order
+func (sf *Splitflap) ModuleHealth() []ModuleHealth {
+	sf.moduleHealthLock.Lock()
+	defer sf.moduleHealthLock.Unlock()
+
+	modules := make([]ModuleHealth, len(sf.moduleHealth))
+	for i, m := range sf.moduleHealth {
+		modules[i] = *m
+		modules[i].Transitions = append([]ModuleTransition{}, m.Transitions...)
+	}
+	return modules
+}
+
+// ResetModuleHealth clears the tracked errors of a module, e.g. after it has been serviced
+func (sf *Splitflap) ResetModuleHealth(idx int) error {
+	sf.moduleHealthLock.Lock()
+	defer sf.moduleHealthLock.Unlock()
+
+	if idx < 0 || idx >= len(sf.moduleHealth) {
+		return errors.New("module index out of range")
+	}
+	m := sf.moduleHealth[idx]
+	sf.moduleHealth[idx] = &ModuleHealth{
+		Index:                    idx,
+		State:                    m.State,
+		CountMissedHome:          m.CountMissedHome,
+		CountUnexpectedHome:      m.CountUnexpectedHome,
+		missedHomeAtLastHome:     m.CountMissedHome,
+		unexpectedHomeAtLastHome: m.CountUnexpectedHome,
+	}
+	return nil
+}
+
+func (sf *Splitflap) AutoHomeConfig() AutoHomeConfig {
+	sf.moduleHealthLock.Lock()
+	defer sf.moduleHealthLock.Unlock()
+	return sf.autoHome
+}
+
+func (sf *Splitflap) SetAutoHomeConfig(config AutoHomeConfig) error {
+	if err := config.Check(); err != nil {
+		return err
+	}
+	sf.moduleHealthLock.Lock()
+	defer sf.moduleHealthLock.Unlock()
+	sf.autoHome = config
+	return nil
+}
+
+// recordModuleStates updates the health of every module from a state report, and returns the indexes of any modules
+// that should be automatically re-homed
+func (sf *Splitflap) recordModuleStates(states []*gen.SplitflapState_ModuleState, now time.Time) []int {
+	sf.moduleHealthLock.Lock()
+	defer sf.moduleHealthLock.Unlock()
+
+	for len(sf.moduleHealth) < len(states) {
+		sf.moduleHealth = append(sf.moduleHealth, &ModuleHealth{
+			Index: len(sf.moduleHealth),
+			State: gen.SplitflapState_ModuleState_NORMAL.String(),
+		})
+	}
+
+	var rehome []int
+	for i, state := range states {
+		m := sf.moduleHealth[i]
+		newState := state.GetState().String()
+		if newState != m.State {
+			m.Transitions = append(m.Transitions, ModuleTransition{From: m.State, To: newState, At: now})
+			if len(m.Transitions) > moduleTransitionHistory {
+				m.Transitions = m.Transitions[len(m.Transitions)-moduleTransitionHistory:]
+			}
+			if isModuleErrorState(state.GetState()) {
+				m.ErrorCount++
+				m.errorsSinceHome++
+				m.LastError = now
+				m.LastErrorState = newState
+				slog.Error("Module entered an error state", "module", i, "state", newState)
+			}
+			m.State = newState
+		}
+		m.CountMissedHome = state.GetCountMissedHome()
+		m.CountUnexpectedHome = state.GetCountUnexpectedHome()
+
+		if sf.shouldAutoHome(m, now) {
+			m.AutoHomes++
+			m.LastAutoHome = now
+			m.errorsSinceHome = 0
+			m.missedHomeAtLastHome = m.CountMissedHome
+			m.unexpectedHomeAtLastHome = m.CountUnexpectedHome
+			rehome = append(rehome, i)
+		}
+	}
+	return rehome
+}
+
+func (sf *Splitflap) shouldAutoHome(m *ModuleHealth, now time.Time) bool {
+	cfg := sf.autoHome
+	if !cfg.Enabled || now.Sub(m.LastAutoHome) < time.Duration(cfg.CooldownSecs)*time.Second {
+		return false
+	}
+	// the firmware's counters may reset when the controller restarts
+	if m.CountMissedHome < m.missedHomeAtLastHome {
+		m.missedHomeAtLastHome = 0
+	}
+	if m.CountUnexpectedHome < m.unexpectedHomeAtLastHome {
+		m.unexpectedHomeAtLastHome = 0
+	}
+	return m.errorsSinceHome >= cfg.ErrorThreshold ||
+		int(m.CountMissedHome-m.missedHomeAtLastHome) >= cfg.MissedHomeThreshold ||
+		int(m.CountUnexpectedHome-m.unexpectedHomeAtLastHome) >= cfg.UnexpectedHomeThreshold
+}
+
+func (sf *Splitflap) autoHomeModules(indexes []int) {
+	for _, idx := range indexes {
+		slog.Info("Automatically re-homing module that exceeded its error thresholds", "module", idx)
+		if err := sf.ResetAndHome(idx); err != nil {
+			slog.Error("Failed to automatically re-home module", "module", idx, "error", err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package usb_serial
+
+import (
+	"testing"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+)
+
+func moduleStates(states ...gen.SplitflapState_ModuleState_State) []*gen.SplitflapState_ModuleState {
+	modules := make([]*gen.SplitflapState_ModuleState, len(states))
+	for i, s := range states {
+		modules[i] = &gen.SplitflapState_ModuleState{State: s}
+	}
+	return modules
+}
+
+func TestSplitflap_recordModuleStates(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	now := time.Now()
+
+	sf.recordModuleStates(moduleStates(gen.SplitflapState_ModuleState_NORMAL, gen.SplitflapState_ModuleState_SENSOR_ERROR), now)
+	sf.recordModuleStates(moduleStates(gen.SplitflapState_ModuleState_NORMAL, gen.SplitflapState_ModuleState_SENSOR_ERROR), now)
+
+	health := sf.ModuleHealth()
+	if len(health) != 2 {
+		t.Fatal("expected health for both modules")
+	}
+	if health[0].ErrorCount != 0 || len(health[0].Transitions) != 0 {
+		t.Fatal("healthy module should have no errors or transitions")
+	}
+	if health[1].ErrorCount != 1 || health[1].LastErrorState != "SENSOR_ERROR" || !health[1].LastError.Equal(now) {
+		t.Fatal("repeated reports of the same error state should only count once", health[1])
+	}
+}
+
+func TestSplitflap_autoHome(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(1, DefaultSimulatorConfig), nil, 1)
+	sf.SetAutoHomeConfig(AutoHomeConfig{
+		Enabled:                 true,
+		ErrorThreshold:          2,
+		MissedHomeThreshold:     100,
+		UnexpectedHomeThreshold: 100,
+		CooldownSecs:            60,
+	})
+	now := time.Now()
+
+	flap := func() []int {
+		rehome := sf.recordModuleStates(moduleStates(gen.SplitflapState_ModuleState_PANIC), now)
+		return append(rehome, sf.recordModuleStates(moduleStates(gen.SplitflapState_ModuleState_NORMAL), now)...)
+	}
+	if len(flap()) != 0 {
+		t.Fatal("module should not be re-homed before reaching the error threshold")
+	}
+	if rehome := flap(); len(rehome) != 1 || rehome[0] != 0 {
+		t.Fatal("module should be re-homed after reaching the error threshold")
+	}
+	flap()
+	if len(flap()) != 0 {
+		t.Fatal("module should not be re-homed again within the cooldown")
+	}
+	if sf.ModuleHealth()[0].AutoHomes != 1 {
+		t.Fatal("expected automatic home to be recorded")
+	}
+}
+
+func TestSplitflap_autoHomeMissedHome(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(1, DefaultSimulatorConfig), nil, 1)
+	states := moduleStates(gen.SplitflapState_ModuleState_NORMAL)
+
+	states[0].CountMissedHome = uint32(DefaultAutoHomeConfig.MissedHomeThreshold - 1)
+	if len(sf.recordModuleStates(states, time.Now())) != 0 {
+		t.Fatal("module should not be re-homed before reaching the missed home threshold")
+	}
+	states[0].CountMissedHome++
+	if len(sf.recordModuleStates(states, time.Now())) != 1 {
+		t.Fatal("module should be re-homed after reaching the missed home threshold")
+	}
+}
@@ -0,0 +1,131 @@
+package usb_serial
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	DirectionToSplitflap   = "to"
+	DirectionFromSplitflap = "from"
+)
+
+// maxCaptureLineBytes bounds a single line of a capture file, comfortably above the largest frame the protocol allows
+const maxCaptureLineBytes = 1024 * 1024
+
+// CapturedFrame is a single frame sent to or received from the splitflap, stored as one JSON line of a capture file
+type CapturedFrame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Frame     []byte    `json:"frame"` // COBS encoded with a CRC32 checksum, as on the wire, without the null ending
+}
+
+// Decode returns the ToSplitflap or FromSplitflap message carried by the frame
+func (f CapturedFrame) Decode() (proto.Message, error) {
+	payload, validCRC32 := utils.ParseCRC32EncodedPayload(f.Frame)
+	if !validCRC32 {
+		return nil, errors.New("invalid CRC32 checksum")
+	}
+
+	var message proto.Message
+	switch f.Direction {
+	case DirectionToSplitflap:
+		message = &gen.ToSplitflap{}
+	case DirectionFromSplitflap:
+		message = &gen.FromSplitflap{}
+	default:
+		return nil, errors.New("unknown direction " + f.Direction)
+	}
+	if err := proto.Unmarshal(payload, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// String formats the frame as a single human-readable line
+func (f CapturedFrame) String() string {
+	arrow := "->"
+	if f.Direction == DirectionFromSplitflap {
+		arrow = "<-"
+	}
+	text := ""
+	message, err := f.Decode()
+	if err != nil {
+		text = fmt.Sprintf("undecodable frame (%s): %x", err, f.Frame)
+	} else {
+		text = protojson.MarshalOptions{}.Format(message)
+	}
+	return fmt.Sprintf("%s %s %s", f.Time.Format("15:04:05.000"), arrow, text)
+}
+
+// ReadCapture reads every frame from a capture file written by a Recorder
+func ReadCapture(r io.Reader) ([]CapturedFrame, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxCaptureLineBytes)
+
+	var frames []CapturedFrame
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame CapturedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, scanner.Err()
+}
+
+// Recorder writes every frame passing through a Splitflap to a capture file, one JSON line per frame
+type Recorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+func (r *Recorder) record(direction string, frame []byte, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err := r.encoder.Encode(CapturedFrame{
+		Time:      now,
+		Direction: direction,
+		Frame:     frame,
+	})
+	if err != nil {
+		slog.Error("Failed to record frame", "error", err)
+	}
+}
+
+// SetRecorder captures all traffic with the hardware from now on. Set it before Start to include the initial handshake
+func (sf *Splitflap) SetRecorder(recorder *Recorder) {
+	sf.recorderLock.Lock()
+	defer sf.recorderLock.Unlock()
+	sf.recorder = recorder
+}
+
+// record writes a frame to the recorder. The lock is held while writing, so that once SetRecorder returns nothing more
+// is written to the previous recorder
+func (sf *Splitflap) record(direction string, frame []byte) {
+	sf.recorderLock.RLock()
+	defer sf.recorderLock.RUnlock()
+
+	if sf.recorder != nil {
+		sf.recorder.record(direction, frame, time.Now())
+	}
+}
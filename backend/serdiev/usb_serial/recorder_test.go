@@ -0,0 +1,91 @@
+package usb_serial
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"github.com/go-playground/assert/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRecorder_capturesBothDirections(t *testing.T) {
+	var capture bytes.Buffer
+	sf := NewSplitflap(NewSimulatorConnection(2, SimulatorConfig{MsPerFlap: 1}), func(state *gen.SplitflapState) {}, 2)
+	sf.SetRecorder(NewRecorder(&capture))
+	sf.Start()
+	time.Sleep(time.Millisecond * 100)
+	sf.SetRecorder(nil)
+
+	frames, err := ReadCapture(bytes.NewReader(capture.Bytes()))
+	assert.Equal(t, err, nil)
+
+	var sentRequest, receivedState bool
+	for _, frame := range frames {
+		message, err := frame.Decode()
+		assert.Equal(t, err, nil)
+		switch m := message.(type) {
+		case *gen.ToSplitflap:
+			sentRequest = sentRequest || m.GetRequestState() != nil
+		case *gen.FromSplitflap:
+			receivedState = receivedState || m.GetSplitflapState() != nil
+		}
+	}
+	assert.Equal(t, sentRequest, true)
+	assert.Equal(t, receivedState, true)
+}
+
+func TestCapturedFrame_String(t *testing.T) {
+	payload, _ := proto.Marshal(&gen.FromSplitflap{Payload: &gen.FromSplitflap_Ack{Ack: &gen.Ack{Nonce: 42}}})
+	encoded := utils.CreatePayloadWithCRC32Checksum(payload)
+	frame := CapturedFrame{
+		Time:      time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC),
+		Direction: DirectionFromSplitflap,
+		Frame:     encoded[:len(encoded)-1],
+	}
+	assert.MatchRegex(t, frame.String(), `^12:30:00\.000 <- \{"ack":\{"nonce":\s*42\}\}$`)
+
+	frame.Frame = []byte{1, 2, 3}
+	assert.MatchRegex(t, frame.String(), "undecodable frame")
+}
+
+func TestReplayConnection(t *testing.T) {
+	var capture bytes.Buffer
+	recorder := NewRecorder(&capture)
+	start := time.Now()
+	for i, nonce := range []uint32{1, 2, 3} {
+		payload, _ := proto.Marshal(&gen.FromSplitflap{Payload: &gen.FromSplitflap_Ack{Ack: &gen.Ack{Nonce: nonce}}})
+		encoded := utils.CreatePayloadWithCRC32Checksum(payload)
+		recorder.record(DirectionFromSplitflap, encoded[:len(encoded)-1], start.Add(time.Duration(i)*time.Second))
+		// frames sent to the splitflap are not replayed
+		recorder.record(DirectionToSplitflap, []byte{1}, start)
+	}
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	if err := os.WriteFile(path, capture.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayConnection(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nonce := range []uint32{1, 2, 3} {
+		frame, err := replay.Read()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, frame[len(frame)-1], byte(0))
+		payload, valid := utils.ParseCRC32EncodedPayload(frame[:len(frame)-1])
+		assert.Equal(t, valid, true)
+		message := &gen.FromSplitflap{}
+		assert.Equal(t, proto.Unmarshal(payload, message), nil)
+		assert.Equal(t, message.GetAck().GetNonce(), nonce)
+	}
+
+	// once the capture is exhausted, reads block until the connection is closed
+	go replay.Close()
+	_, err = replay.Read()
+	assert.NotEqual(t, err, nil)
+}
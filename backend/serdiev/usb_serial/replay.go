@@ -0,0 +1,117 @@
+package usb_serial
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ReplayConnection is a SerialConnection that plays back the frames a splitflap sent in a capture file, with their
+// original timing, so that problems seen on real hardware can be reproduced offline. Anything written to it is ignored
+type ReplayConnection struct {
+	speed float64
+
+	lock   sync.Mutex
+	frames []CapturedFrame
+	next   int
+	start  time.Time
+	closed chan struct{}
+}
+
+// NewReplayConnection loads a capture file. speed scales the original timing (2 plays twice as fast); 0 replays every
+// frame without waiting
+func NewReplayConnection(path string, speed float64) (*ReplayConnection, error) {
+	if speed < 0 {
+		return nil, errors.New("replay speed cannot be negative")
+	}
+	r := &ReplayConnection{speed: speed}
+	if err := r.Open(path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ReplayConnection) Open(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	captured, err := ReadCapture(f)
+	if err != nil {
+		return err
+	}
+	var frames []CapturedFrame
+	for _, frame := range captured {
+		if frame.Direction == DirectionFromSplitflap {
+			frames = append(frames, frame)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.frames = frames
+	r.next = 0
+	r.start = time.Now()
+	r.closed = make(chan struct{})
+	return nil
+}
+
+// Reopen resumes playback where it left off
+func (r *ReplayConnection) Reopen() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	select {
+	case <-r.closed:
+		r.closed = make(chan struct{})
+	default:
+	}
+	return nil
+}
+
+func (r *ReplayConnection) Write(_ []byte) error {
+	return nil
+}
+
+// Read returns the next recorded frame once it is due. After the last frame it blocks until the connection is closed
+func (r *ReplayConnection) Read() ([]byte, error) {
+	r.lock.Lock()
+	closed := r.closed
+	if r.next >= len(r.frames) {
+		r.lock.Unlock()
+		<-closed
+		return []byte{}, errors.New("replay connection is closed")
+	}
+	frame := r.frames[r.next]
+	r.next++
+	var wait time.Duration
+	if r.speed > 0 {
+		offset := frame.Time.Sub(r.frames[0].Time)
+		wait = time.Duration(float64(offset)/r.speed) - time.Since(r.start)
+	}
+	r.lock.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-closed:
+			return []byte{}, errors.New("replay connection is closed")
+		}
+	}
+	return append(append([]byte{}, frame.Frame...), 0), nil
+}
+
+func (r *ReplayConnection) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	select {
+	case <-r.closed:
+	default:
+		close(r.closed)
+	}
+	return nil
+}
@@ -2,16 +2,23 @@ package usb_serial
 
 import (
 	"bufio"
+	"errors"
 	"log/slog"
+	"sync"
 
 	"go.bug.st/serial"
+	"go.bug.st/serial/enumerator"
 )
 
 const DEFAULT_BAUDRATE = 230400
 const retryTimeout float32 = 0.25
 
+// openPort opens a serial port. Tests replace it, to reconnect without hardware
+var openPort = serial.Open
+
 type SerialConnection interface {
 	Open(portName string) error
+	Reopen() error // reopen the connection after it was lost (e.g. the device was unplugged and plugged back in)
 	Write(data []byte) error
 	Read() ([]byte, error)
 	Close() error
@@ -44,8 +51,16 @@ func NewSerialConnectionOnPort(port string) *Serial {
 	return &s
 }
 
+// Serial is a connection over a serial port. The port can be reopened by the read loop while the write loop is writing
+// to it, so lock guards which port is open
 type Serial struct {
-	serial *serial.Port
+	lock     sync.Mutex
+	serial   *serial.Port
+	reader   *bufio.Reader
+	portName string
+
+	// USB identity of the opened port, so we can find the device again if it comes back under a different port name
+	vid, pid, serialNumber string
 }
 
 func (s *Serial) getSerial() serial.Port {
@@ -53,22 +68,95 @@ func (s *Serial) getSerial() serial.Port {
 }
 
 func (s *Serial) Open(portName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.open(portName)
+}
+
+// open is Open, with the lock held
+func (s *Serial) open(portName string) error {
 	mode := serial.Mode{
 		BaudRate: DEFAULT_BAUDRATE,
 		DataBits: 8,
 	}
 
-	port, err := serial.Open(portName, &mode)
+	port, err := openPort(portName, &mode)
 	if err != nil {
 		return err
 	}
 
 	s.serial = &port
+	s.reader = bufio.NewReader(port)
+	s.portName = portName
+	s.lookupUSBIdentity()
 	return nil
 }
 
+// Reopen tries the port that was previously opened, and if that fails, looks for the same USB device on any port
+func (s *Serial) Reopen() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.portName == "" {
+		return errors.New("serial port was never opened")
+	}
+	if s.serial != nil {
+		s.getSerial().Close()
+		s.serial = nil
+	}
+
+	vid, pid, serialNumber := s.vid, s.pid, s.serialNumber
+	err := s.open(s.portName)
+	if err == nil || vid == "" {
+		return err
+	}
+
+	port := findUSBPort(vid, pid, serialNumber)
+	if port == "" {
+		return err
+	}
+	slog.Info("Found USB device on a different port", "old", s.portName, "new", port, "vid", vid, "pid", pid)
+	return s.open(port)
+}
+
+func (s *Serial) lookupUSBIdentity() {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		slog.Error("Failed to get detailed port list", "error", err)
+		return
+	}
+	for _, p := range ports {
+		if p.Name == s.portName && p.IsUSB {
+			s.vid, s.pid, s.serialNumber = p.VID, p.PID, p.SerialNumber
+			return
+		}
+	}
+}
+
+// findUSBPort returns the name of the port with the given USB VID/PID (and serial number, if known), or "" if none
+func findUSBPort(vid, pid, serialNumber string) string {
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		slog.Error("Failed to get detailed port list", "error", err)
+		return ""
+	}
+	for _, p := range ports {
+		if p.IsUSB && p.VID == vid && p.PID == pid && (serialNumber == "" || p.SerialNumber == serialNumber) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
 func (s *Serial) Write(data []byte) error {
-	_, err := s.getSerial().Write(data)
+	// like reading, writing is done without the lock; if the port is closed underneath it, the write fails
+	s.lock.Lock()
+	port := s.serial
+	s.lock.Unlock()
+	if port == nil {
+		return errors.New("serial port is closed")
+	}
+	_, err := (*port).Write(data)
 	if err != nil {
 		slog.Error("failed writing")
 	}
@@ -80,7 +168,14 @@ func (s *Serial) Read() ([]byte, error) {
 	buffer := []byte{}
 	// _, err := s.getSerial().Read(buffer)
 
-	reader := bufio.NewReader(s.getSerial())
+	// reading blocks until a frame arrives, so it's done without the lock, for Close to be able to interrupt it
+	s.lock.Lock()
+	reader := s.reader
+	s.lock.Unlock()
+	if reader == nil {
+		return buffer, errors.New("serial port is closed")
+	}
+	// the reader has to persist between reads, otherwise anything it buffered past the end of this frame is lost
 	reply, err := reader.ReadBytes(byte(0))
 	if err != nil {
 		return buffer, err
@@ -95,5 +190,11 @@ func (s *Serial) Read() ([]byte, error) {
 }
 
 func (s *Serial) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.serial == nil {
+		return nil
+	}
 	return s.getSerial().Close()
 }
@@ -0,0 +1,99 @@
+package usb_serial
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// fakePort stands in for a serial port, counting what is written to it
+type fakePort struct {
+	serial.Port
+	written *atomic.Int64
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (p *fakePort) Write(data []byte) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, errors.New("port is closed")
+	default:
+	}
+	p.written.Add(int64(len(data)))
+	return len(data), nil
+}
+
+func (p *fakePort) Read(data []byte) (int, error) {
+	<-p.closed
+	return 0, errors.New("port is closed")
+}
+
+func (p *fakePort) Close() error {
+	p.once.Do(func() { close(p.closed) })
+	return nil
+}
+
+func TestSerial_reopenWhileWriting(t *testing.T) {
+	var written atomic.Int64
+	open := openPort
+	openPort = func(portName string, mode *serial.Mode) (serial.Port, error) {
+		return &fakePort{written: &written, closed: make(chan struct{})}, nil
+	}
+	defer func() { openPort = open }()
+
+	s := &Serial{}
+	if err := s.Open("fake"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the write loop keeps writing while the read loop reconnects, as it does when the device is unplugged
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				s.Write([]byte{1, 2, 3, 0})
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			if _, err := s.Read(); err != nil {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+			}
+		}
+	}()
+	deadline := time.Now().Add(time.Second * 5)
+	for i := 0; i < 20; i++ {
+		// wait for a write to go through on this connection before dropping it
+		before := written.Load()
+		for written.Load() == before {
+			if time.Now().After(deadline) {
+				t.Fatal("writes should go through between reconnects")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		s.Close()
+		if err := s.Reopen(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	s.Close()
+	wg.Wait()
+}
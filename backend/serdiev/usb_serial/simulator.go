@@ -0,0 +1,288 @@
+package usb_serial
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+// SimulatorAlphabet is the character set the simulated hardware reports, matching the standard 52-flap splitflap
+var SimulatorAlphabet = []rune{' ', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L',
+	'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y',
+	'Z', 'g', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', 'r',
+	'.', '?', '-', '$', '\'', '#', ':', 'd', ',', '!', '@', '&', 'w'}
+
+// simulatorFrameBuffer is how many frames the simulator holds for the reader before it starts dropping them
+const simulatorFrameBuffer = 256
+
+type SimulatorConfig struct {
+	MsPerFlap       int     // how long a module takes to advance a single flap
+	SensorErrorRate float64 // chance (0-1), each time a module arrives at its target, that it reports SENSOR_ERROR instead
+	DropAckRate     float64 // chance (0-1) that a message is not acknowledged
+	Seed            int64   // seed for fault injection, so that runs are reproducible
+}
+
+var DefaultSimulatorConfig = SimulatorConfig{
+	MsPerFlap: 65,
+}
+
+type simulatedModule struct {
+	flap          int
+	remaining     int // flaps left to travel before arriving at the target
+	state         gen.SplitflapState_ModuleState_State
+	movementNonce uint32
+	resetNonce    uint32
+}
+
+// SimulatorConnection is a SerialConnection that behaves like real splitflap hardware: modules only move forward
+// through the alphabet, one flap at a time, and report intermediate states while they move
+type SimulatorConnection struct {
+	config  SimulatorConfig
+	modules []*simulatedModule
+	frames  chan []byte
+	rand    *rand.Rand
+	lock    sync.Mutex
+	stop    chan struct{}
+}
+
+func NewSimulatorConnection(modules int, config SimulatorConfig) *SimulatorConnection {
+	if config.MsPerFlap < 1 {
+		config.MsPerFlap = DefaultSimulatorConfig.MsPerFlap
+	}
+	s := &SimulatorConnection{
+		config:  config,
+		modules: make([]*simulatedModule, modules),
+		rand:    rand.New(rand.NewSource(config.Seed)),
+	}
+	for i := range s.modules {
+		s.modules[i] = &simulatedModule{state: gen.SplitflapState_ModuleState_NORMAL}
+	}
+	s.Open("")
+	return s
+}
+
+func (s *SimulatorConnection) Open(_ string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop != nil {
+		return nil
+	}
+	s.frames = make(chan []byte, simulatorFrameBuffer)
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+
+	// real hardware announces its character set when it boots
+	s.sendLocked(s.generalState())
+	return nil
+}
+
+func (s *SimulatorConnection) Reopen() error {
+	return s.Open("")
+}
+
+func (s *SimulatorConnection) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		close(s.frames)
+		s.stop = nil
+	}
+	return nil
+}
+
+func (s *SimulatorConnection) Read() ([]byte, error) {
+	s.lock.Lock()
+	frames := s.frames
+	s.lock.Unlock()
+
+	frame, ok := <-frames
+	if !ok {
+		return []byte{}, errors.New("simulator connection is closed")
+	}
+	return frame, nil
+}
+
+func (s *SimulatorConnection) Write(data []byte) error {
+	for _, frame := range bytes.Split(data, []byte{0}) {
+		if len(frame) == 0 {
+			continue
+		}
+		payload, validCRC32 := utils.ParseCRC32EncodedPayload(frame)
+		if !validCRC32 {
+			slog.Error("Simulator received a frame with an invalid checksum")
+			continue
+		}
+		message := &gen.ToSplitflap{}
+		if err := proto.Unmarshal(payload, message); err != nil {
+			slog.Error("Simulator failed to unmarshal message", "error", err)
+			continue
+		}
+		s.handle(message)
+	}
+	return nil
+}
+
+func (s *SimulatorConnection) handle(message *gen.ToSplitflap) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+	if s.rand.Float64() >= s.config.DropAckRate {
+		s.sendLocked(&gen.FromSplitflap{
+			Payload: &gen.FromSplitflap_Ack{Ack: &gen.Ack{Nonce: message.GetNonce()}},
+		})
+	}
+
+	switch message.GetPayload().(type) {
+	case *gen.ToSplitflap_RequestState:
+		s.sendLocked(s.generalState())
+		s.sendLocked(s.splitflapState())
+	case *gen.ToSplitflap_SplitflapConfig:
+		s.applyConfig(message.GetSplitflapConfig())
+	case *gen.ToSplitflap_SplitflapCommand:
+		s.applyCommand(message.GetSplitflapCommand())
+	}
+}
+
+func (s *SimulatorConnection) applyConfig(config *gen.SplitflapConfig) {
+	numFlaps := len(SimulatorAlphabet)
+	for i, moduleConfig := range config.GetModules() {
+		if i >= len(s.modules) {
+			break
+		}
+		m := s.modules[i]
+		if m.state != gen.SplitflapState_ModuleState_NORMAL {
+			continue
+		}
+		target := int(moduleConfig.GetTargetFlapIndex()) % numFlaps
+		m.remaining = (target - m.flap + numFlaps) % numFlaps
+		// a changed nonce forces a full rotation, even if the module is already showing the target flap
+		if moduleConfig.GetMovementNonce() != m.movementNonce || moduleConfig.GetResetNonce() != m.resetNonce {
+			m.remaining += numFlaps
+		}
+		m.movementNonce = moduleConfig.GetMovementNonce()
+		m.resetNonce = moduleConfig.GetResetNonce()
+	}
+}
+
+func (s *SimulatorConnection) applyCommand(command *gen.SplitflapCommand) {
+	numFlaps := len(SimulatorAlphabet)
+	for i, moduleCommand := range command.GetModules() {
+		if i >= len(s.modules) {
+			break
+		}
+		m := s.modules[i]
+		switch moduleCommand.GetAction() {
+		case gen.SplitflapCommand_ModuleCommand_RESET_AND_HOME:
+			// homing clears any error, and spins the module around to its home position before continuing to its target
+			m.state = gen.SplitflapState_ModuleState_NORMAL
+			target := (m.flap + m.remaining) % numFlaps
+			m.remaining = numFlaps - m.flap + target
+		case gen.SplitflapCommand_ModuleCommand_GO_TO_FLAP:
+			if m.state == gen.SplitflapState_ModuleState_NORMAL {
+				target := int(moduleCommand.GetParam()) % numFlaps
+				m.remaining = (target - m.flap + numFlaps) % numFlaps
+			}
+		}
+	}
+}
+
+// run steps every moving module forward by one flap at the configured rate, reporting state whenever anything moved
+func (s *SimulatorConnection) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(s.config.MsPerFlap) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.lock.Lock()
+			// a tick can win the race against the connection being closed, which closes the frames too
+			if s.stop != stop {
+				s.lock.Unlock()
+				return
+			}
+			if s.step() {
+				s.sendLocked(s.splitflapState())
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+func (s *SimulatorConnection) step() bool {
+	moved := false
+	for _, m := range s.modules {
+		if m.remaining == 0 {
+			continue
+		}
+		moved = true
+		m.flap = (m.flap + 1) % len(SimulatorAlphabet)
+		m.remaining--
+		if m.remaining == 0 && s.rand.Float64() < s.config.SensorErrorRate {
+			m.state = gen.SplitflapState_ModuleState_SENSOR_ERROR
+		}
+	}
+	return moved
+}
+
+func (s *SimulatorConnection) splitflapState() *gen.FromSplitflap {
+	modules := make([]*gen.SplitflapState_ModuleState, len(s.modules))
+	for i, m := range s.modules {
+		modules[i] = &gen.SplitflapState_ModuleState{
+			State:     m.state,
+			FlapIndex: uint32(m.flap),
+			Moving:    m.remaining > 0,
+			HomeState: m.flap == 0,
+		}
+	}
+	return &gen.FromSplitflap{
+		Payload: &gen.FromSplitflap_SplitflapState{
+			SplitflapState: &gen.SplitflapState{
+				Modules:     modules,
+				LoopbacksOk: true,
+			},
+		},
+	}
+}
+
+func (s *SimulatorConnection) generalState() *gen.FromSplitflap {
+	chars := make([]byte, len(SimulatorAlphabet))
+	for i, c := range SimulatorAlphabet {
+		chars[i] = byte(c)
+	}
+	return &gen.FromSplitflap{
+		Payload: &gen.FromSplitflap_GeneralState{
+			GeneralState: &gen.GeneralState{
+				FlapCharacterSet: chars,
+			},
+		},
+	}
+}
+
+// sendLocked queues a message to be read, dropping it if nobody is reading. s.lock must be held
+func (s *SimulatorConnection) sendLocked(message *gen.FromSplitflap) {
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		slog.Error("Simulator failed to marshal message", "error", err)
+		return
+	}
+	select {
+	case s.frames <- utils.CreatePayloadWithCRC32Checksum(payload):
+	default:
+		slog.Error("Simulator frame buffer is full, dropping message")
+	}
+}
@@ -0,0 +1,105 @@
+package usb_serial
+
+import (
+	"testing"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"github.com/go-playground/assert/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+func readSimulatorMessage(t *testing.T, s *SimulatorConnection) *gen.FromSplitflap {
+	t.Helper()
+	frames := make(chan []byte, 1)
+	go func() {
+		frame, err := s.Read()
+		if err == nil {
+			frames <- frame
+		}
+	}()
+	select {
+	case frame := <-frames:
+		payload, valid := utils.ParseCRC32EncodedPayload(frame[:len(frame)-1])
+		if !valid {
+			t.Fatal("simulator sent a frame with an invalid checksum")
+		}
+		message := &gen.FromSplitflap{}
+		if err := proto.Unmarshal(payload, message); err != nil {
+			t.Fatal(err)
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the simulator")
+	}
+	return nil
+}
+
+func writeSimulatorMessage(t *testing.T, s *SimulatorConnection, message *gen.ToSplitflap) {
+	t.Helper()
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.Write(utils.CreatePayloadWithCRC32Checksum(payload)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulatorConnection_movesOneFlapAtATime(t *testing.T) {
+	s := NewSimulatorConnection(1, SimulatorConfig{MsPerFlap: 1})
+	defer s.Close()
+
+	assert.NotEqual(t, readSimulatorMessage(t, s).GetGeneralState(), nil)
+
+	writeSimulatorMessage(t, s, &gen.ToSplitflap{
+		Nonce: 7,
+		Payload: &gen.ToSplitflap_SplitflapConfig{SplitflapConfig: &gen.SplitflapConfig{
+			Modules: []*gen.SplitflapConfig_ModuleConfig{{TargetFlapIndex: 3}},
+		}},
+	})
+	assert.Equal(t, readSimulatorMessage(t, s).GetAck().GetNonce(), uint32(7))
+
+	var positions []uint32
+	for {
+		module := readSimulatorMessage(t, s).GetSplitflapState().GetModules()[0]
+		positions = append(positions, module.GetFlapIndex())
+		if !module.GetMoving() {
+			break
+		}
+	}
+	assert.Equal(t, positions, []uint32{1, 2, 3})
+}
+
+func TestSimulatorConnection_dropsAcks(t *testing.T) {
+	s := NewSimulatorConnection(1, SimulatorConfig{MsPerFlap: 1000, DropAckRate: 1})
+	defer s.Close()
+
+	assert.NotEqual(t, readSimulatorMessage(t, s).GetGeneralState(), nil)
+
+	writeSimulatorMessage(t, s, &gen.ToSplitflap{
+		Nonce:   1,
+		Payload: &gen.ToSplitflap_RequestState{RequestState: &gen.RequestState{}},
+	})
+	// the request is still handled, it just isn't acknowledged
+	assert.NotEqual(t, readSimulatorMessage(t, s).GetGeneralState(), nil)
+	assert.NotEqual(t, readSimulatorMessage(t, s).GetSplitflapState(), nil)
+}
+
+func TestSimulatorConnection_sensorErrors(t *testing.T) {
+	s := NewSimulatorConnection(1, SimulatorConfig{MsPerFlap: 1, SensorErrorRate: 1})
+	defer s.Close()
+
+	readSimulatorMessage(t, s)
+	writeSimulatorMessage(t, s, &gen.ToSplitflap{
+		Payload: &gen.ToSplitflap_SplitflapCommand{SplitflapCommand: &gen.SplitflapCommand{
+			Modules: []*gen.SplitflapCommand_ModuleCommand{{Action: gen.SplitflapCommand_ModuleCommand_GO_TO_FLAP, Param: 1}},
+		}},
+	})
+	readSimulatorMessage(t, s)
+
+	module := readSimulatorMessage(t, s).GetSplitflapState().GetModules()[0]
+	assert.Equal(t, module.GetFlapIndex(), uint32(1))
+	assert.Equal(t, module.GetState(), gen.SplitflapState_ModuleState_SENSOR_ERROR)
+}
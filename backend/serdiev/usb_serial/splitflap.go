@@ -1,6 +1,7 @@
 package usb_serial
 
 import (
+	"context"
 	"errors"
 	gen "github.com/denverquane/go-splitflap/serdiev/generated"
 	"github.com/denverquane/go-splitflap/serdiev/utils"
@@ -16,40 +17,64 @@ const (
 	ForceMovementNone ForceMovement = iota
 	ForceMovementOnlyNonBlank
 	ForceMovementAll
-	RetryTime     = time.Millisecond * 500
-	HoldCharacter = uint32('a')
+	RetryTime = time.Millisecond * 500
+	// HoldCharacter in text leaves a module where it is. It's in Unicode's private use area, so no flap alphabet can
+	// contain it
+	HoldCharacter = uint32('\uE000')
 )
 
 var GlobalAlphabet []rune
 
 type ForceMovement int
 
-type EnqueuedMessage struct {
-	nonce uint32
-	bytes []byte // bytes with CRC32 + null ending
-}
-
 type Splitflap struct {
 	serial          SerialConnection
-	outQueue        chan EnqueuedMessage
+	outQueue        *transmitQueue
 	ackQueue        chan uint32
 	nextNonce       uint32
-	run             bool
+	ctx             context.Context
+	cancel          context.CancelFunc
 	lock            sync.Mutex
 	currentConfig   *gen.SplitflapConfig
 	numModules      int
 	handleReadState func(state *gen.SplitflapState)
+
+	statusLock     sync.RWMutex
+	status         ConnectionStatus
+	onStatusChange func(status ConnectionStatus)
+
+	healthLock     sync.RWMutex
+	healthHistory  []Health
+	onHealthChange func(health Health)
+
+	moduleHealthLock sync.Mutex
+	moduleHealth     []*ModuleHealth
+	autoHome         AutoHomeConfig
+
+	recorderLock sync.RWMutex
+	recorder     *Recorder
+
+	wear        *WearTracker // guarded by lock
+	wearSource  string
+	transmitted *gen.SplitflapConfig // the last config the hardware acknowledged. Only used by the write loop
 }
 
 func NewSplitflap(serialInstance SerialConnection, handleState func(state *gen.SplitflapState), modules int) *Splitflap {
+	ctx, cancel := context.WithCancel(context.Background())
 	s := &Splitflap{
 		serial:          serialInstance,
-		outQueue:        make(chan EnqueuedMessage, 100),
-		ackQueue:        make(chan uint32, 100),
+		outQueue:        newTransmitQueue(),
+		ackQueue:        make(chan uint32, ackBuffer),
 		nextNonce:       uint32(rand.Intn(256)),
-		run:             true,
+		ctx:             ctx,
+		cancel:          cancel,
 		currentConfig:   nil,
 		handleReadState: handleState,
+		status: ConnectionStatus{
+			Connected: true,
+			Since:     time.Now(),
+		},
+		autoHome: DefaultAutoHomeConfig,
 	}
 	if modules > 0 {
 		s.initializeModuleList(modules)
@@ -78,14 +103,17 @@ func (sf *Splitflap) readLoop() {
 	slog.Info("Read loop started")
 	buffer := []byte{}
 	for {
-		if !sf.run {
+		newBytes, err := sf.serial.Read()
+		if !sf.running() {
+			slog.Info("Stop running, exiting read loop")
 			return
 		}
-
-		newBytes, err := sf.serial.Read()
 		if err != nil {
-			slog.Error("Error reading from serial", "error", err)
-			return
+			if !sf.reconnect(err) {
+				return
+			}
+			buffer = []byte{}
+			continue
 		}
 
 		if len(newBytes) == 0 {
@@ -98,6 +126,7 @@ func (sf *Splitflap) readLoop() {
 			continue
 		}
 
+		sf.record(DirectionFromSplitflap, buffer[:len(buffer)-1])
 		sf.processFrame(buffer[:len(buffer)-1])
 		buffer = []byte{}
 	}
@@ -120,8 +149,7 @@ func (sf *Splitflap) processFrame(decoded []byte) {
 
 	switch message.GetPayload().(type) {
 	case *gen.FromSplitflap_Ack:
-		nonce := message.GetAck().GetNonce()
-		sf.ackQueue <- nonce
+		sf.receiveAck(message.GetAck().GetNonce())
 	case *gen.FromSplitflap_GeneralState:
 		if GlobalAlphabet == nil {
 			chars := message.GetGeneralState().GetFlapCharacterSet()
@@ -141,63 +169,11 @@ func (sf *Splitflap) processFrame(decoded []byte) {
 			slog.Info("Number of reported modules changed\n", "old", sf.numModules, "new", numModulesReported)
 		}
 
+		sf.autoHomeModules(sf.recordModuleStates(message.GetSplitflapState().GetModules(), time.Now()))
 		sf.handleReadState(message.GetSplitflapState())
-	}
-}
-
-func (sf *Splitflap) waitingForOutgoingMessage() bool {
-	return len(sf.outQueue) == 0
-}
-
-func (sf *Splitflap) waitingForIncomingMessage() bool {
-	return len(sf.ackQueue) == 0
-}
-
-func (sf *Splitflap) writeLoop() {
-
-	slog.Info("Write loop started")
-
-	for {
-		if !sf.run {
-			slog.Info("Stop running, exiting write loop")
-			return
-		}
-
-		if sf.waitingForOutgoingMessage() {
-			continue
-		}
 
-		enqueuedMessage := <-sf.outQueue
-
-		nextRetry := time.Now()
-		writeCount := 0
-		for {
-			if !sf.run {
-				slog.Info("Stop running, exiting write loop")
-				return
-			}
-
-			if time.Now().After(nextRetry) {
-				if writeCount > 0 {
-					slog.Info("Failed to write message, resetting queue")
-					sf.outQueue = make(chan EnqueuedMessage, 100)
-					break
-				}
-
-				writeCount++
-				sf.serial.Write(enqueuedMessage.bytes)
-				nextRetry = time.Now().Add(RetryTime)
-			}
-
-			if sf.waitingForIncomingMessage() {
-				continue
-			}
-
-			latestAckNonce := <-sf.ackQueue
-			if enqueuedMessage.nonce == latestAckNonce {
-				break
-			}
-		}
+	case *gen.FromSplitflap_SupervisorState:
+		sf.recordHealth(message.GetSupervisorState())
 	}
 }
 
@@ -217,7 +193,7 @@ func (sf *Splitflap) SetTextWithMovement(text string, forceMovement ForceMovemen
 	}
 
 	// Pad with blanks if text is shorter than the number of modules
-	for i := len(text); i < sf.numModules; i++ {
+	for i := len(positions); i < sf.numModules; i++ {
 		positions = append(positions, uint32(AlphabetIndex(' ')))
 	}
 
@@ -230,7 +206,7 @@ func (sf *Splitflap) SetTextWithMovement(text string, forceMovement ForceMovemen
 			forceMovementList = append(forceMovementList, AlphabetIndex(c) != 0 && uint32(c) != HoldCharacter)
 		}
 		// Pad with false if text is shorter than the number of modules
-		for i := len(text); i < sf.numModules; i++ {
+		for i := len(forceMovementList); i < sf.numModules; i++ {
 			forceMovementList = append(forceMovementList, false)
 		}
 	case ForceMovementAll:
@@ -249,6 +225,11 @@ func (sf *Splitflap) SpinCharacter(idx int) {
 	sf.lock.Lock()
 	defer sf.lock.Unlock()
 
+	if sf.supervisorFault() {
+		slog.Error("Not spinning character while the power supervisor reports a fault")
+		return
+	}
+
 	sf.currentConfig.Modules[idx].MovementNonce = (sf.currentConfig.Modules[idx].MovementNonce + 1) % 256
 	message := &gen.ToSplitflap{
 		Payload: &gen.ToSplitflap_SplitflapConfig{
@@ -267,6 +248,10 @@ func (sf *Splitflap) setPositions(positions []uint32, forceMovementList []bool)
 		return errors.New("cannot set positions before the number of modules is known")
 	}
 
+	if sf.supervisorFault() {
+		return errors.New("not moving modules while the power supervisor reports a fault")
+	}
+
 	if len(positions) > sf.numModules {
 		return errors.New("more positions specified than modules")
 	}
@@ -294,32 +279,10 @@ func (sf *Splitflap) setPositions(positions []uint32, forceMovementList []bool)
 	return nil
 }
 
-func (sf *Splitflap) enqueueMessage(message *gen.ToSplitflap) {
-	message.Nonce = sf.nextNonce
-	sf.nextNonce++
-
-	payload, err := proto.Marshal(message)
-	if err != nil {
-		slog.Error("Error marshaling message", "error", err)
-		return
-	}
-
-	newMessage := EnqueuedMessage{
-		nonce: message.Nonce,
-		bytes: utils.CreatePayloadWithCRC32Checksum(payload),
-	}
-
-	sf.outQueue <- newMessage
-
-	approxQLength := len(sf.outQueue)
-	// TODO: handle error in some way
-	// logger.Info().Msgf("Out q length: %d\n", approxQLength)
-	if approxQLength > 10 {
-		slog.Info("Output queue length is high! (%d) Is the splitflap still connected and functional?", "length", approxQLength)
-	}
-}
-
 func (sf *Splitflap) RequestState() {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
 	message := gen.ToSplitflap{}
 	message.Payload = &gen.ToSplitflap_RequestState{
 		RequestState: &gen.RequestState{},
@@ -1,7 +1,12 @@
 package usb_serial
 
 import (
+	"errors"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
 )
 
 func TestAlphabetDistance(t *testing.T) {
@@ -18,3 +23,70 @@ func TestAlphabetDistance(t *testing.T) {
 	}
 
 }
+
+// flakyConnection fails its first read, as if the device was unplugged, then works normally once reopened
+type flakyConnection struct {
+	*SimulatorConnection
+	failed  atomic.Bool
+	reopens atomic.Int32
+}
+
+func (f *flakyConnection) Read() ([]byte, error) {
+	if !f.failed.Swap(true) {
+		return nil, errors.New("device unplugged")
+	}
+	time.Sleep(time.Millisecond)
+	return f.SimulatorConnection.Read()
+}
+
+func (f *flakyConnection) Reopen() error {
+	f.reopens.Add(1)
+	return nil
+}
+
+func TestSplitflap_reconnect(t *testing.T) {
+	conn := &flakyConnection{SimulatorConnection: NewSimulatorConnection(4, DefaultSimulatorConfig)}
+	statuses := make(chan ConnectionStatus, 10)
+
+	sf := NewSplitflap(conn, func(state *gen.SplitflapState) {}, 4)
+	sf.SetStatusHandler(func(status ConnectionStatus) {
+		statuses <- status
+	})
+	sf.Start()
+	defer sf.Stop()
+
+	if status := <-statuses; status.Connected {
+		t.Fatal("expected to be notified of the disconnect first")
+	}
+	select {
+	case status := <-statuses:
+		if !status.Connected {
+			t.Fatal("expected to be notified of the reconnect")
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("timed out waiting for reconnect")
+	}
+	if conn.reopens.Load() != 1 {
+		t.Fatal("expected exactly one reopen attempt")
+	}
+}
+
+func TestSplitflap_SetText_hold(t *testing.T) {
+	alphabet := GlobalAlphabet
+	GlobalAlphabet = []rune(" ABCa")
+	defer func() { GlobalAlphabet = alphabet }()
+
+	sf := NewSplitflap(NewSimulatorConnection(3, DefaultSimulatorConfig), func(state *gen.SplitflapState) {}, 3)
+	if err := sf.SetText("AB"); err != nil {
+		t.Fatal(err)
+	}
+	// a held module stays put, 'a' is just another flap, and the module the text doesn't reach is blanked
+	if err := sf.SetText(string(rune(HoldCharacter)) + "a"); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []uint32{1, 4, 0} {
+		if target := sf.currentConfig.Modules[i].TargetFlapIndex; target != expected {
+			t.Error("module", i, "should target flap", expected, "not", target)
+		}
+	}
+}
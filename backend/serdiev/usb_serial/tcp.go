@@ -0,0 +1,109 @@
+package usb_serial
+
+import (
+	"bufio"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TCPScheme      = "tcp://"
+	tcpDialTimeout = time.Second * 5
+)
+
+// TCPConnection carries the same COBS+CRC32 framed bytes as the USB serial link, but over a TCP socket. This lets the
+// backend run on a different machine than the one the splitflap is plugged into (see cmd/serial-bridge)
+type TCPConnection struct {
+	addr   string
+	conn   net.Conn
+	reader *bufio.Reader
+	lock   sync.Mutex
+}
+
+// IsTCPPort returns true if the port refers to a network address (tcp://host:port) rather than a local serial port
+func IsTCPPort(port string) bool {
+	return strings.HasPrefix(port, TCPScheme)
+}
+
+func NewTCPConnection(addr string) *TCPConnection {
+	t := TCPConnection{}
+	err := t.Open(addr)
+	if err != nil {
+		slog.Error("Failed to connect over TCP", "address", addr, "error", err)
+		return nil
+	}
+
+	slog.Info("Connecting", "address", addr)
+	return &t
+}
+
+// Open connects to an address, either as host:port or tcp://host:port
+func (t *TCPConnection) Open(addr string) error {
+	addr = strings.TrimPrefix(addr, TCPScheme)
+	conn, err := net.DialTimeout("tcp", addr, tcpDialTimeout)
+	if err != nil {
+		return err
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.addr = addr
+	t.conn = conn
+	t.reader = bufio.NewReader(conn)
+	return nil
+}
+
+func (t *TCPConnection) Reopen() error {
+	t.lock.Lock()
+	addr := t.addr
+	t.lock.Unlock()
+
+	if addr == "" {
+		return errors.New("tcp connection was never opened")
+	}
+	t.Close()
+	return t.Open(addr)
+}
+
+func (t *TCPConnection) Write(data []byte) error {
+	t.lock.Lock()
+	conn := t.conn
+	t.lock.Unlock()
+
+	if conn == nil {
+		return errors.New("tcp connection is closed")
+	}
+	_, err := conn.Write(data)
+	if err != nil {
+		slog.Error("failed writing")
+	}
+	return err
+}
+
+func (t *TCPConnection) Read() ([]byte, error) {
+	t.lock.Lock()
+	reader := t.reader
+	t.lock.Unlock()
+
+	if reader == nil {
+		return []byte{}, errors.New("tcp connection is closed")
+	}
+	return reader.ReadBytes(byte(0))
+}
+
+func (t *TCPConnection) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	t.reader = nil
+	return err
+}
@@ -0,0 +1,103 @@
+package usb_serial
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"github.com/go-playground/assert/v2"
+)
+
+// echoFrames stands in for a serial-bridge: it accepts connections and echoes every frame back
+func echoFrames(t *testing.T) (net.Listener, chan net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				reader := bufio.NewReader(conn)
+				for {
+					frame, err := reader.ReadBytes(0)
+					if err != nil {
+						return
+					}
+					conn.Write(frame)
+				}
+			}()
+		}
+	}()
+	return ln, conns
+}
+
+func TestTCPConnection_frames(t *testing.T) {
+	ln, _ := echoFrames(t)
+	defer ln.Close()
+
+	conn := NewTCPConnection(TCPScheme + ln.Addr().String())
+	if conn == nil {
+		t.Fatal("failed to connect")
+	}
+	defer conn.Close()
+
+	// two frames in one write should still be read back as separate frames
+	first := utils.CreatePayloadWithCRC32Checksum([]byte{1, 2, 3})
+	second := utils.CreatePayloadWithCRC32Checksum([]byte{4, 5})
+	if err := conn.Write(append(append([]byte{}, first...), second...)); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, ok := utils.ParseCRC32EncodedPayload(frame[:len(frame)-1])
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte{1, 2, 3}, payload)
+
+	frame, err = conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, ok = utils.ParseCRC32EncodedPayload(frame[:len(frame)-1])
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte{4, 5}, payload)
+}
+
+func TestTCPConnection_Reopen(t *testing.T) {
+	ln, conns := echoFrames(t)
+	defer ln.Close()
+
+	conn := NewTCPConnection(ln.Addr().String())
+	if conn == nil {
+		t.Fatal("failed to connect")
+	}
+	defer conn.Close()
+
+	// the bridge dropping the connection should surface as a read error
+	(<-conns).Close()
+	if _, err := conn.Read(); err == nil {
+		t.Fatal("expected a read error once the remote end closes")
+	}
+
+	if err := conn.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	frame := utils.CreatePayloadWithCRC32Checksum([]byte{9})
+	if err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	echoed, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, frame, echoed)
+}
@@ -0,0 +1,229 @@
+package usb_serial
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/denverquane/go-splitflap/serdiev/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxWriteAttempts is how many times a message is written without being acknowledged before it is dropped
+	MaxWriteAttempts = 3
+	// maxQueueLength is how many messages can wait to be sent before the oldest are dropped
+	maxQueueLength = 100
+	// ackBuffer is how many acks can arrive before the write loop gets to them
+	ackBuffer = 100
+)
+
+type EnqueuedMessage struct {
+	nonce  uint32
+	bytes  []byte // bytes with CRC32 + null ending
+	config bool   // the message carries a full SplitflapConfig, which supersedes any config queued before it
+
+	snapshot   *gen.SplitflapConfig // a copy of the config carried, to count the wear it causes once it is transmitted
+	wearSource string
+}
+
+// TransmitStats counts what happened to the messages sent to the hardware since the Splitflap was created
+type TransmitStats struct {
+	Enqueued    int `json:"enqueued"`
+	Sent        int `json:"sent"`      // writes to the connection, including retries
+	Retries     int `json:"retries"`   // writes of a message that wasn't acknowledged in time
+	Acked       int `json:"acked"`     // messages that were acknowledged by the hardware
+	Dropped     int `json:"dropped"`   // messages given up on: unacknowledged, disconnected, or the queue overflowed
+	Coalesced   int `json:"coalesced"` // configs that were replaced by a newer config before they were sent
+	StaleAcks   int `json:"stale_acks"`
+	QueueLength int `json:"queue_length"`
+}
+
+// transmitQueue holds the messages waiting to be written, and wakes the write loop when there is something to send
+type transmitQueue struct {
+	lock     sync.Mutex
+	messages []EnqueuedMessage
+	stats    TransmitStats
+	signal   chan struct{}
+}
+
+func newTransmitQueue() *transmitQueue {
+	return &transmitQueue{signal: make(chan struct{}, 1)}
+}
+
+func (q *transmitQueue) push(message EnqueuedMessage) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// a config describes the whole display, so an older config that hasn't been sent yet is pointless
+	if message.config {
+		kept := q.messages[:0]
+		for _, queued := range q.messages {
+			if queued.config {
+				q.stats.Coalesced++
+				continue
+			}
+			kept = append(kept, queued)
+		}
+		q.messages = kept
+	}
+	if len(q.messages) >= maxQueueLength {
+		slog.Error("Output queue is full, dropping the oldest message. Is the splitflap still connected and functional?")
+		q.messages = q.messages[1:]
+		q.stats.Dropped++
+	}
+	q.messages = append(q.messages, message)
+	q.stats.Enqueued++
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the next message to send, and false if the queue is empty
+func (q *transmitQueue) pop() (EnqueuedMessage, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.messages) == 0 {
+		return EnqueuedMessage{}, false
+	}
+	message := q.messages[0]
+	q.messages = q.messages[1:]
+	return message, true
+}
+
+func (q *transmitQueue) count(update func(stats *TransmitStats)) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	update(&q.stats)
+}
+
+// TransmitStats returns the counters of the transmit pipeline
+func (sf *Splitflap) TransmitStats() TransmitStats {
+	sf.outQueue.lock.Lock()
+	defer sf.outQueue.lock.Unlock()
+
+	stats := sf.outQueue.stats
+	stats.QueueLength = len(sf.outQueue.messages)
+	return stats
+}
+
+func (sf *Splitflap) enqueueMessage(message *gen.ToSplitflap) {
+	message.Nonce = sf.nextNonce
+	sf.nextNonce++
+
+	payload, err := proto.Marshal(message)
+	if err != nil {
+		slog.Error("Error marshaling message", "error", err)
+		return
+	}
+
+	queued := EnqueuedMessage{
+		nonce: message.Nonce,
+		bytes: utils.CreatePayloadWithCRC32Checksum(payload),
+	}
+	if config := message.GetSplitflapConfig(); config != nil {
+		queued.config = true
+		queued.snapshot = proto.Clone(config).(*gen.SplitflapConfig)
+		queued.wearSource = sf.wearSource
+	}
+	sf.outQueue.push(queued)
+}
+
+// receiveAck hands an ack from the hardware to the write loop
+func (sf *Splitflap) receiveAck(nonce uint32) {
+	select {
+	case sf.ackQueue <- nonce:
+	default:
+		// the write loop only ever waits for one nonce, so if it has fallen this far behind these acks are stale anyway
+		sf.outQueue.count(func(stats *TransmitStats) { stats.StaleAcks++ })
+	}
+}
+
+// writeLoop sends queued messages one at a time, waiting for each to be acknowledged before sending the next
+func (sf *Splitflap) writeLoop() {
+	slog.Info("Write loop started")
+
+	for sf.running() {
+		message, ok := sf.outQueue.pop()
+		if !ok {
+			select {
+			case <-sf.ctx.Done():
+			case <-sf.outQueue.signal:
+			}
+			continue
+		}
+
+		// the full config is re-sent once we reconnect, so there's no point trying to write while disconnected
+		if !sf.Connected() {
+			sf.outQueue.count(func(stats *TransmitStats) { stats.Dropped++ })
+			continue
+		}
+
+		sf.transmit(message)
+	}
+	slog.Info("Stop running, exiting write loop")
+}
+
+// transmit writes a message until it is acknowledged, it has used up its attempts, or the Splitflap is stopped
+func (sf *Splitflap) transmit(message EnqueuedMessage) {
+	retry := time.NewTimer(RetryTime)
+	defer retry.Stop()
+
+	for attempt := 1; attempt <= MaxWriteAttempts; attempt++ {
+		sf.outQueue.count(func(stats *TransmitStats) {
+			stats.Sent++
+			if attempt > 1 {
+				stats.Retries++
+			}
+		})
+		sf.record(DirectionToSplitflap, message.bytes[:len(message.bytes)-1])
+		if err := sf.serial.Write(message.bytes); err != nil {
+			slog.Error("Failed to write message", "nonce", message.nonce, "attempt", attempt, "error", err)
+		}
+		retry.Reset(RetryTime)
+
+	waitForAck:
+		for {
+			select {
+			case <-sf.ctx.Done():
+				return
+			case nonce := <-sf.ackQueue:
+				if nonce == message.nonce {
+					sf.outQueue.count(func(stats *TransmitStats) { stats.Acked++ })
+					sf.recordWear(message)
+					return
+				}
+				sf.outQueue.count(func(stats *TransmitStats) { stats.StaleAcks++ })
+			case <-retry.C:
+				break waitForAck
+			}
+		}
+	}
+
+	slog.Error("Message was never acknowledged, dropping it", "nonce", message.nonce, "attempts", MaxWriteAttempts)
+	sf.outQueue.count(func(stats *TransmitStats) { stats.Dropped++ })
+}
+
+// Stop shuts down the read and write loops and closes the connection to the hardware, then saves the wear counted for
+// its modules
+func (sf *Splitflap) Stop() {
+	sf.cancel()
+	sf.serial.Close()
+
+	sf.lock.Lock()
+	tracker := sf.wear
+	sf.lock.Unlock()
+	if tracker != nil {
+		if err := tracker.Save(); err != nil {
+			slog.Error("Failed to save wear stats", "file", tracker.path, "error", err.Error())
+		}
+	}
+}
+
+func (sf *Splitflap) running() bool {
+	return sf.ctx.Err() == nil
+}
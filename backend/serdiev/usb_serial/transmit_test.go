@@ -0,0 +1,79 @@
+package usb_serial
+
+import (
+	"testing"
+	"time"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/go-playground/assert/v2"
+)
+
+func waitForStats(t *testing.T, sf *Splitflap, done func(stats TransmitStats) bool) TransmitStats {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 3)
+	for time.Now().Before(deadline) {
+		if stats := sf.TransmitStats(); done(stats) {
+			return stats
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatal("timed out waiting for transmit stats", sf.TransmitStats())
+	return TransmitStats{}
+}
+
+func TestTransmitQueue_coalescesConfigs(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	sf.RequestState()
+	assert.Equal(t, sf.SetText("AB"), nil)
+	assert.Equal(t, sf.SetText("CD"), nil)
+	assert.Equal(t, sf.SetText("EF"), nil)
+
+	stats := sf.TransmitStats()
+	assert.Equal(t, stats.Enqueued, 4)
+	assert.Equal(t, stats.Coalesced, 2)
+	assert.Equal(t, stats.QueueLength, 2)
+
+	first, _ := sf.outQueue.pop()
+	assert.Equal(t, first.config, false)
+	last, _ := sf.outQueue.pop()
+	assert.Equal(t, last.config, true)
+	_, ok := sf.outQueue.pop()
+	assert.Equal(t, ok, false)
+}
+
+func TestSplitflap_acknowledgedMessages(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, SimulatorConfig{MsPerFlap: 1}), func(state *gen.SplitflapState) {}, 2)
+	sf.Start()
+	defer sf.Stop()
+
+	// acks for messages that aren't in flight are ignored
+	sf.receiveAck(sf.nextNonce + 1000)
+	assert.Equal(t, sf.SetText("AB"), nil)
+
+	stats := waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Acked == 2 })
+	assert.Equal(t, stats.Retries, 0)
+	assert.Equal(t, stats.Dropped, 0)
+	assert.Equal(t, stats.StaleAcks, 1)
+}
+
+func TestSplitflap_unacknowledgedMessagesAreDropped(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, SimulatorConfig{MsPerFlap: 1, DropAckRate: 1}), func(state *gen.SplitflapState) {}, 2)
+	sf.Start()
+	defer sf.Stop()
+
+	stats := waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Dropped == 1 })
+	assert.Equal(t, stats.Sent, MaxWriteAttempts)
+	assert.Equal(t, stats.Retries, MaxWriteAttempts-1)
+	assert.Equal(t, stats.Acked, 0)
+}
+
+func TestSplitflap_Stop(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), func(state *gen.SplitflapState) {}, 2)
+	sf.Start()
+	sf.Stop()
+
+	sent := sf.TransmitStats().Sent
+	assert.Equal(t, sf.SetText("AB"), nil)
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, sf.TransmitStats().Sent, sent)
+}
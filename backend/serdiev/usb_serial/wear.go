@@ -0,0 +1,255 @@
+package usb_serial
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// wearHistoryDays is how many daily buckets of wear are kept
+const wearHistoryDays = 90
+
+// ModuleWear counts how much a single module has been used
+type ModuleWear struct {
+	Steps     uint64 `json:"steps"`     // flaps advanced
+	Rotations uint64 `json:"rotations"` // times the module went past its home flap
+	Commands  uint64 `json:"commands"`  // times the module was sent to a new flap, or told to spin
+}
+
+func (w *ModuleWear) add(other ModuleWear) {
+	w.Steps += other.Steps
+	w.Rotations += other.Rotations
+	w.Commands += other.Commands
+}
+
+// WearDay is the wear of one day (in UTC), for every module in physical order, and the steps caused by each source of
+// text (e.g. "dashboard:clock")
+type WearDay struct {
+	Date    string            `json:"date"` // YYYY-MM-DD
+	Modules []ModuleWear      `json:"modules"`
+	Sources map[string]uint64 `json:"sources"`
+}
+
+// WearStats is the wear of every module, in physical order, since the stats were first recorded, and the last
+// wearHistoryDays days of it
+type WearStats struct {
+	Since   time.Time         `json:"since"`
+	Modules []ModuleWear      `json:"modules"`
+	Sources map[string]uint64 `json:"sources"`
+	Days    []WearDay         `json:"days"`
+}
+
+// WearTracker counts the wear of a splitflap's modules, derived from the flaps they are sent to, and keeps the counts in
+// a file so they survive restarts
+type WearTracker struct {
+	lock  sync.Mutex
+	path  string
+	stats WearStats
+	dirty bool
+}
+
+// LoadWearTracker reads the wear stats kept at path, or starts new ones if the file doesn't exist yet
+func LoadWearTracker(path string) (*WearTracker, error) {
+	w := &WearTracker{
+		path: path,
+		stats: WearStats{
+			Since:   time.Now().UTC(),
+			Sources: make(map[string]uint64),
+		},
+	}
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(bytes, &w.stats); err != nil {
+		return nil, errors.New("invalid wear stats file " + path + ": " + err.Error())
+	}
+	if w.stats.Sources == nil {
+		w.stats.Sources = make(map[string]uint64)
+	}
+	return w, nil
+}
+
+// Stats returns a copy of the wear counted so far
+func (w *WearTracker) Stats() WearStats {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	stats := WearStats{
+		Since:   w.stats.Since,
+		Modules: append([]ModuleWear{}, w.stats.Modules...),
+		Sources: make(map[string]uint64, len(w.stats.Sources)),
+		Days:    make([]WearDay, len(w.stats.Days)),
+	}
+	for source, steps := range w.stats.Sources {
+		stats.Sources[source] = steps
+	}
+	for i, day := range w.stats.Days {
+		stats.Days[i] = WearDay{
+			Date:    day.Date,
+			Modules: append([]ModuleWear{}, day.Modules...),
+			Sources: make(map[string]uint64, len(day.Sources)),
+		}
+		for source, steps := range day.Sources {
+			stats.Days[i].Sources[source] = steps
+		}
+	}
+	return stats
+}
+
+// record adds the wear of one module being sent to a new flap
+func (w *WearTracker) record(module int, wear ModuleWear, source string, now time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	date := now.UTC().Format(time.DateOnly)
+	if len(w.stats.Days) == 0 || w.stats.Days[len(w.stats.Days)-1].Date != date {
+		w.stats.Days = append(w.stats.Days, WearDay{Date: date, Sources: make(map[string]uint64)})
+		if len(w.stats.Days) > wearHistoryDays {
+			w.stats.Days = w.stats.Days[len(w.stats.Days)-wearHistoryDays:]
+		}
+	}
+	day := &w.stats.Days[len(w.stats.Days)-1]
+
+	for len(w.stats.Modules) <= module {
+		w.stats.Modules = append(w.stats.Modules, ModuleWear{})
+	}
+	for len(day.Modules) <= module {
+		day.Modules = append(day.Modules, ModuleWear{})
+	}
+	w.stats.Modules[module].add(wear)
+	day.Modules[module].add(wear)
+	if source != "" {
+		w.stats.Sources[source] += wear.Steps
+		day.Sources[source] += wear.Steps
+	}
+	w.dirty = true
+}
+
+// Save writes the stats to the tracker's file, if anything changed since they were last saved. The file is replaced
+// in one go, so a crash while saving doesn't lose the stats
+func (w *WearTracker) Save() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.dirty {
+		return nil
+	}
+	bytes, err := json.MarshalIndent(w.stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), w.path); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// SaveEvery saves the stats periodically, for as long as the process runs
+func (w *WearTracker) SaveEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := w.Save(); err != nil {
+			slog.Error("Failed to save wear stats", "file", w.path, "error", err.Error())
+		}
+	}
+}
+
+// SetWearTracker counts the wear of the modules from now on. Pass nil to stop counting
+func (sf *Splitflap) SetWearTracker(tracker *WearTracker) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+	sf.wear = tracker
+}
+
+// SetWearSource labels the wear caused by the text sent from now on, e.g. with the dashboard it came from
+func (sf *Splitflap) SetWearSource(source string) {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+	sf.wearSource = source
+}
+
+// WearStats returns the wear counted for the modules
+func (sf *Splitflap) WearStats() (WearStats, error) {
+	sf.lock.Lock()
+	tracker := sf.wear
+	sf.lock.Unlock()
+
+	if tracker == nil {
+		return WearStats{}, errors.New("wear statistics are not being recorded")
+	}
+	return tracker.Stats(), nil
+}
+
+// recordWear counts the wear of the hardware moving from the last config it acknowledged to the config a message
+// carries, which it has just acknowledged. Configs that were replaced before they were sent, or never acknowledged,
+// don't move the modules, so they aren't counted. Only called by the write loop
+func (sf *Splitflap) recordWear(message EnqueuedMessage) {
+	if message.snapshot == nil {
+		return
+	}
+	previous := sf.transmitted
+	sf.transmitted = message.snapshot
+
+	sf.lock.Lock()
+	tracker := sf.wear
+	sf.lock.Unlock()
+	if tracker == nil {
+		return
+	}
+
+	now := time.Now()
+	for i, module := range message.snapshot.Modules {
+		// until the first config is acknowledged, the modules are assumed to be home
+		var from, nonce uint32
+		if i < len(previous.GetModules()) {
+			from, nonce = previous.Modules[i].TargetFlapIndex, previous.Modules[i].MovementNonce
+		}
+		if wear, moved := moduleWear(from, module.TargetFlapIndex, module.MovementNonce != nonce); moved {
+			tracker.record(i, wear, message.wearSource, now)
+		}
+	}
+}
+
+// moduleWear is the flaps a module travels to get from one flap to another, which is a full rotation if it is forced to
+// move to the flap it's already on. It is false if the module doesn't move
+func moduleWear(from, to uint32, forced bool) (ModuleWear, bool) {
+	flaps := uint32(len(GlobalAlphabet))
+	if flaps == 0 || (from == to && !forced) {
+		return ModuleWear{}, false
+	}
+	from %= flaps
+	to %= flaps
+	steps := (to + flaps - from) % flaps
+	if steps == 0 {
+		steps = flaps
+	}
+	return ModuleWear{
+		Steps:     uint64(steps),
+		Rotations: uint64((from + steps) / flaps),
+		Commands:  1,
+	}, true
+}
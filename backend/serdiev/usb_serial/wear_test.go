@@ -0,0 +1,63 @@
+package usb_serial
+
+import (
+	"path/filepath"
+	"testing"
+
+	gen "github.com/denverquane/go-splitflap/serdiev/generated"
+	"github.com/go-playground/assert/v2"
+)
+
+func TestSplitflap_wear(t *testing.T) {
+	alphabet := GlobalAlphabet
+	GlobalAlphabet = []rune(" ABC")
+	defer func() { GlobalAlphabet = alphabet }()
+
+	path := filepath.Join(t.TempDir(), "stats.json")
+	tracker, err := LoadWearTracker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := NewSplitflap(NewSimulatorConnection(2, SimulatorConfig{MsPerFlap: 1}), func(state *gen.SplitflapState) {}, 2)
+	sf.SetWearTracker(tracker)
+	sf.SetWearSource("dashboard:main")
+
+	// C is replaced by A before it is ever sent, so module 0 only goes blank -> A
+	hold := string(rune(HoldCharacter))
+	assert.Equal(t, sf.SetText("C"+hold), nil)
+	assert.Equal(t, sf.SetText("A"+hold), nil)
+	sf.Start()
+	waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Acked == 2 })
+
+	// module 1 is held, and then forced round to where it already is. Then module 0 goes A -> C -> blank (wrapping
+	// past home)
+	assert.Equal(t, sf.SetTextWithMovement(hold+" ", ForceMovementAll), nil)
+	waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Acked == 3 })
+	assert.Equal(t, sf.SetText("C"+hold), nil)
+	waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Acked == 4 })
+	assert.Equal(t, sf.SetText(" "+hold), nil)
+	waitForStats(t, sf, func(stats TransmitStats) bool { return stats.Acked == 5 })
+
+	stats, err := sf.WearStats()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, stats.Modules, []ModuleWear{
+		{Steps: 1 + 2 + 1, Rotations: 1, Commands: 3},
+		{Steps: 4, Rotations: 1, Commands: 1},
+	})
+	assert.Equal(t, len(stats.Days), 1)
+	assert.Equal(t, stats.Days[0].Modules, stats.Modules)
+	assert.Equal(t, stats.Sources["dashboard:main"], uint64(8))
+
+	// the stats are saved when the splitflap stops, and survive a restart
+	sf.Stop()
+	reloaded, err := LoadWearTracker(path)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, reloaded.Stats(), stats)
+}
+
+func TestSplitflap_wearNotTracked(t *testing.T) {
+	sf := NewSplitflap(NewSimulatorConnection(2, DefaultSimulatorConfig), nil, 2)
+	if _, err := sf.WearStats(); err == nil {
+		t.Fatal("stats should be unavailable without a tracker")
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateAlertResponse returns the ID of a new alert, for cancelling it later
+type CreateAlertResponse struct {
+	ID string `json:"id"`
+}
+
+// SetupAlertHandlers registers all alert-related routes
+func SetupAlertHandlers(r chi.Router, display *splitflap.Display) {
+	r.Get("/", getAlerts(display))
+	r.Post("/", createAlert(display))
+	r.Delete("/{alertID}", cancelAlert(display))
+}
+
+// getAlerts returns every pending alert, starting with the one being shown
+func getAlerts(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(display.Alerts())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// createAlert queues an alert, which interrupts the display once it is the highest priority alert
+func createAlert(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var alert splitflap.Alert
+		err := json.NewDecoder(r.Body).Decode(&alert)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if alert.Source == "" {
+			alert.Source = "api"
+		}
+
+		id, err := display.PushAlert(alert)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bytes, err := json.Marshal(CreateAlertResponse{ID: id})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func cancelAlert(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := display.CancelAlert(chi.URLParam(r, "alertID"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/denverquane/go-splitflap/routine"
 	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/denverquane/go-splitflap/transition"
 	"github.com/go-chi/chi/v5"
 	"log/slog"
 	"net/http"
@@ -16,12 +17,18 @@ func SetupDashboardHandlers(r chi.Router, display *splitflap.Display) {
 	r.Post("/{dashboardName}", createOrUpdateDashboard(display))
 	r.Delete("/{dashboardName}", deleteDashboard(display))
 	r.Post("/{dashboardName}/activate", activateDashboard(display))
+	r.Post("/{dashboardName}/transition", setDashboardTransition(display))
+}
+
+// DashboardTransitionRequest represents the request body for setting the transition of a dashboard
+type DashboardTransitionRequest struct {
+	Transition transition.Effect `json:"transition"`
 }
 
 // getAllDashboards returns all dashboards
 func getAllDashboards(display *splitflap.Display) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		bytes, err := json.Marshal(display.Dashboards)
+		bytes, err := json.Marshal(display.GetDashboards())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -42,7 +49,7 @@ func deleteDashboard(display *splitflap.Display) http.HandlerFunc {
 		dashboardName := chi.URLParam(r, "dashboardName")
 		err := display.DeleteDashboard(dashboardName)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 			return
 		}
 
@@ -54,7 +61,7 @@ func deleteDashboard(display *splitflap.Display) http.HandlerFunc {
 func activateDashboard(display *splitflap.Display) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		dashboardName := chi.URLParam(r, "dashboardName")
-		if _, ok := display.Dashboards[dashboardName]; !ok {
+		if _, ok := display.GetDashboards()[dashboardName]; !ok {
 			http.Error(w, "no dashboard found with that name", http.StatusBadRequest)
 			return
 		}
@@ -71,6 +78,29 @@ func activateDashboard(display *splitflap.Display) http.HandlerFunc {
 	}
 }
 
+// setDashboardTransition sets how the display changes over to a dashboard when it is activated
+func setDashboardTransition(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dashboardName := chi.URLParam(r, "dashboardName")
+
+		var req DashboardTransitionRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = display.SetDashboardTransition(dashboardName, req.Transition)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(dashboardName))
+	}
+}
+
 func createOrUpdateDashboard(display *splitflap.Display) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		dashboardName := chi.URLParam(r, "dashboardName")
@@ -88,18 +118,18 @@ func createOrUpdateDashboard(display *splitflap.Display) http.HandlerFunc {
 		}
 
 		created := false
-		if _, ok := display.Dashboards[dashboardName]; !ok {
+		if _, ok := display.GetDashboards()[dashboardName]; !ok {
 			err = display.CreateDashboard(dashboardName)
 			if err != nil {
 				slog.Error(err.Error())
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 				return
 			}
 			created = true
 		}
 
 		for _, routineJson := range routineJsons {
-			if rout, ok := routine.AllRoutines[routineJson.Type]; !ok {
+			if rout, ok := routine.NewRoutine(routineJson.Type); !ok {
 				http.Error(w, "no routine found by that type", http.StatusBadRequest)
 				return
 			} else {
@@ -128,7 +158,7 @@ func createOrUpdateDashboard(display *splitflap.Display) http.HandlerFunc {
 						display.DeleteDashboard(dashboardName)
 					}
 					slog.Error(err.Error())
-					http.Error(w, err.Error(), http.StatusBadRequest)
+					http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 					return
 				}
 			}
@@ -2,31 +2,42 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
 	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/denverquane/go-splitflap/transition"
 	"github.com/go-chi/chi/v5"
 )
 
 // UpdateDisplayRequest represents the request body for updating display text
 type UpdateDisplayRequest struct {
-	Text         string `json:"text"`
-	DurationSecs int64  `json:"duration_secs"`
+	Text         string            `json:"text"`
+	DurationSecs int64             `json:"duration_secs"`
+	Transition   transition.Effect `json:"transition"`
 }
 
 // SetupDisplayHandlers registers all display-related routes
-func SetupDisplayHandlers(r chi.Router, display *splitflap.Display) {
+func SetupDisplayHandlers(r chi.Router, display *splitflap.Display, client *splitflap.Client) {
 	r.Get("/state", getDisplayState(display))
+	r.Get("/connection", getConnectionStatus(client))
 	r.Get("/size", getDisplaySize(display))
 	r.Post("/clear", clearDisplay(display))
 	r.Post("/update", updateDisplay(display))
 	r.Get("/alphabet", getAlphabet())
 	r.Get("/translations", getTranslations(display))
 	r.Post("/translations", updateTranslations(display))
+	r.Get("/motion", getMotionPolicy(display))
+	r.Post("/motion", updateMotionPolicy(display))
+	r.Post("/preview", previewMotion(display))
+}
+
+// PreviewMotionRequest represents the request body for estimating the cost of showing text
+type PreviewMotionRequest struct {
+	Text string `json:"text"`
 }
 
 func getDisplayState(display *splitflap.Display) http.HandlerFunc {
@@ -35,6 +46,18 @@ func getDisplayState(display *splitflap.Display) http.HandlerFunc {
 	}
 }
 
+// getConnectionStatus returns whether the hardware is currently connected
+func getConnectionStatus(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(client.Status())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
 // getDisplaySize returns the current display dimensions
 func getDisplaySize(display *splitflap.Display) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -65,7 +88,7 @@ func getTranslations(display *splitflap.Display) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Convert rune map to string map for more consistent JSON serialization
 		stringMap := make(map[string]string)
-		for src, dst := range display.Translations {
+		for src, dst := range display.GetTranslations() {
 			stringMap[string(src)] = string(dst)
 		}
 
@@ -108,17 +131,11 @@ func updateDisplay(display *splitflap.Display) http.HandlerFunc {
 			return
 		}
 
-		// Validate the text
-		if len(req.Text) == 0 {
-			http.Error(w, "Text cannot be empty", http.StatusBadRequest)
+		if err = showText(display, req, "api"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		dur := time.Duration(req.DurationSecs) * time.Second
-
-		// Update the display with the new text
-		display.Set(req.Text, dur)
-
 		// Broadcast the state change to all WebSocket clients
 		BroadcastStateChange()
 
@@ -126,6 +143,29 @@ func updateDisplay(display *splitflap.Display) http.HandlerFunc {
 	}
 }
 
+// showText sets the display's text, regardless of active dashboard or rotation. Text with a duration is shown as an
+// alert instead, so that whatever was showing before comes back afterwards
+func showText(display *splitflap.Display, req UpdateDisplayRequest, source string) error {
+	if len(req.Text) == 0 {
+		return errors.New("text cannot be empty")
+	}
+	if err := transition.Check(req.Transition); err != nil {
+		return err
+	}
+
+	if req.DurationSecs > 0 {
+		_, err := display.PushAlert(splitflap.Alert{
+			Text:       req.Text,
+			TTLSecs:    int(req.DurationSecs),
+			Source:     source,
+			Transition: req.Transition,
+		})
+		return err
+	}
+	display.Set(req.Text, req.Transition)
+	return nil
+}
+
 // UpdateTranslationsRequest represents the request body for updating character translations
 type UpdateTranslationsRequest map[string]string
 
@@ -162,14 +202,11 @@ func updateTranslations(display *splitflap.Display) http.HandlerFunc {
 			translations[srcRunes[0]] = dstRunes[0]
 		}
 
-		// Update display translations
-		display.Translations = translations
-
-		// Save the updated display configuration
-		err = splitflap.WriteDisplayToFile(display, display.GetFilepath())
+		// Update and save the display translations
+		err = display.SetTranslations(translations)
 		if err != nil {
 			slog.Error("Failed to save display configuration", "error", err)
-			http.Error(w, "Failed to save display configuration", http.StatusInternalServerError)
+			http.Error(w, "Failed to save display configuration: "+err.Error(), errorStatus(err, http.StatusInternalServerError))
 			return
 		}
 
@@ -179,3 +216,56 @@ func updateTranslations(display *splitflap.Display) http.HandlerFunc {
 		respondJSON(w, []byte(`{"status":"ok"}`))
 	}
 }
+
+// getMotionPolicy returns how the display trades what it shows for less flap travel
+func getMotionPolicy(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(display.GetMotionPolicy())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func updateMotionPolicy(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var policy splitflap.MotionPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := display.SetMotionPolicy(policy); err != nil {
+			slog.Error("Failed to save display configuration", "error", err)
+			http.Error(w, "Failed to save display configuration: "+err.Error(), errorStatus(err, http.StatusInternalServerError))
+			return
+		}
+
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+// previewMotion estimates how far the flaps would travel, and how long they would take to settle, if the display
+// changed to the given text
+func previewMotion(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PreviewMotionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len([]rune(req.Text)) > display.Size.Width*display.Size.Height {
+			http.Error(w, "Text is longer than the display", http.StatusBadRequest)
+			return
+		}
+
+		bytes, err := json.Marshal(display.EstimateMotion(req.Text))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
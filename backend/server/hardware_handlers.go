@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+// ModuleCharacterRequest represents the request body for commands that take a flap, specified as a character
+type ModuleCharacterRequest struct {
+	Character string `json:"character"`
+}
+
+// HealthResponse holds the latest power supervisor report, and the recent history of reports
+type HealthResponse struct {
+	Current *usb_serial.Health  `json:"current"`
+	History []usb_serial.Health `json:"history"`
+}
+
+// SetupHardwareHandlers registers all routes that talk directly to the splitflap hardware. Module indexes are the
+// physical (wiring) order of the modules, not their position in the display layout
+func SetupHardwareHandlers(r chi.Router, client *splitflap.Client) {
+	r.Get("/health", getHealth(client))
+	r.Get("/transmit", getTransmitStats(client))
+	r.Get("/stats", getWearStats(client))
+	r.Post("/home", homeAllModules(client))
+	r.Post("/offsets/save", saveAllOffsets(client))
+	r.Get("/autohome", getAutoHomeConfig(client))
+	r.Post("/autohome", updateAutoHomeConfig(client))
+	r.Get("/modules", getModuleHealth(client))
+	r.Route("/modules/{idx}", func(r chi.Router) {
+		r.Post("/health/reset", moduleCommand(client, (*usb_serial.Splitflap).ResetModuleHealth))
+		r.Post("/home", moduleCommand(client, (*usb_serial.Splitflap).ResetAndHome))
+		r.Post("/offset/tenth", moduleCommand(client, (*usb_serial.Splitflap).IncreaseOffsetTenth))
+		r.Post("/offset/half", moduleCommand(client, (*usb_serial.Splitflap).IncreaseOffsetHalf))
+		r.Post("/offset", moduleCharacterCommand(client, (*usb_serial.Splitflap).SetOffset))
+		r.Post("/goto", moduleCharacterCommand(client, (*usb_serial.Splitflap).GoToFlap))
+	})
+}
+
+// getHealth returns the power supervisor state and its recent history
+func getHealth(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		resp := HealthResponse{History: hardware.HealthHistory()}
+		if current, ok := hardware.Health(); ok {
+			resp.Current = &current
+		}
+		bytes, err := json.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// getWearStats returns how far every module has travelled, in total and per day, and what the display was showing
+// while it did
+func getWearStats(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		stats, err := hardware.WearStats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		bytes, err := json.Marshal(stats)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// getTransmitStats returns the counters of messages sent to the hardware, including retries and drops
+func getTransmitStats(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		bytes, err := json.Marshal(hardware.TransmitStats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// getModuleHealth returns the tracked errors and state transitions of every module, in physical order
+func getModuleHealth(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		bytes, err := json.Marshal(hardware.ModuleHealth())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func getAutoHomeConfig(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		bytes, err := json.Marshal(hardware.AutoHomeConfig())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// updateAutoHomeConfig changes the thresholds after which struggling modules are automatically re-homed
+func updateAutoHomeConfig(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		config := hardware.AutoHomeConfig()
+		if err = json.NewDecoder(r.Body).Decode(&config); err != nil {
+			slog.Error("Failed to parse request body", "error", err)
+			http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+			return
+		}
+		if err = hardware.SetAutoHomeConfig(config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+func homeAllModules(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err = hardware.ResetAndHomeAll(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+// saveAllOffsets persists the (calibrated) offsets of every module to the controller
+func saveAllOffsets(client *splitflap.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, err := client.Hardware()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err = hardware.SaveAllOffsets(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+// moduleCommand sends a command that only needs a module index
+func moduleCommand(client *splitflap.Client, command func(*usb_serial.Splitflap, int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, idx, err := hardwareModule(client, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = command(hardware, idx); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+// moduleCharacterCommand sends a command that needs a module index and a flap, given as a character in the request body
+func moduleCharacterCommand(client *splitflap.Client, command func(*usb_serial.Splitflap, int, uint32) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hardware, idx, err := hardwareModule(client, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req ModuleCharacterRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Error("Failed to parse request body", "error", err)
+			http.Error(w, "Failed to parse request body", http.StatusBadRequest)
+			return
+		}
+		chars := []rune(req.Character)
+		if len(usb_serial.GlobalAlphabet) == 0 {
+			http.Error(w, "the display's alphabet is not known yet", http.StatusServiceUnavailable)
+			return
+		}
+		if len(chars) != 1 {
+			http.Error(w, "character must be a single Unicode character", http.StatusBadRequest)
+			return
+		}
+		flapIndex := usb_serial.AlphabetIndex(chars[0])
+		if flapIndex == 0 && chars[0] != usb_serial.GlobalAlphabet[0] {
+			http.Error(w, "character is not in the display's alphabet", http.StatusBadRequest)
+			return
+		}
+
+		if err = command(hardware, idx, uint32(flapIndex)); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+func hardwareModule(client *splitflap.Client, r *http.Request) (*usb_serial.Splitflap, int, error) {
+	hardware, err := client.Hardware()
+	if err != nil {
+		return nil, 0, err
+	}
+	idx, err := strconv.Atoi(chi.URLParam(r, "idx"))
+	if err != nil {
+		return nil, 0, errors.New("module index must be an integer")
+	}
+	if idx < 0 || idx >= hardware.NumModules() {
+		return nil, 0, errors.New("module index out of range")
+	}
+	return hardware, idx, nil
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/denverquane/go-splitflap/splitflap"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// the option of the dashboard select that stands for no active dashboard
+const haNoDashboard = "(none)"
+
+// Home Assistant only allows these characters in the node and object IDs of discovery topics
+var haInvalidID = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// haEntity is the discovery config of one Home Assistant entity. See
+// https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery
+type haEntity struct {
+	Name                string   `json:"name"`
+	UniqueID            string   `json:"unique_id"`
+	Icon                string   `json:"icon,omitempty"`
+	DeviceClass         string   `json:"device_class,omitempty"`
+	EntityCategory      string   `json:"entity_category,omitempty"`
+	StateTopic          string   `json:"state_topic,omitempty"`
+	ValueTemplate       string   `json:"value_template,omitempty"`
+	JSONAttributesTopic string   `json:"json_attributes_topic,omitempty"`
+	CommandTopic        string   `json:"command_topic,omitempty"`
+	CommandTemplate     string   `json:"command_template,omitempty"`
+	PayloadPress        string   `json:"payload_press,omitempty"`
+	Options             []string `json:"options,omitempty"`
+	Max                 int      `json:"max,omitempty"`
+	AvailabilityTopic   string   `json:"availability_topic"`
+	Device              haDevice `json:"device"`
+}
+
+// haDevice groups every entity of a display into one Home Assistant device
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+}
+
+// HardwareHealth is what is published about a display's hardware, for Home Assistant's health sensors. It leaves out
+// anything that changes with every report, like the uptime, so it is only published when something worth knowing
+// changes
+type HardwareHealth struct {
+	Connected    bool   `json:"connected"`
+	State        string `json:"state"` // the power supervisor's state, or UNKNOWN if it hasn't reported one
+	FaultType    string `json:"fault_type,omitempty"`
+	FaultMessage string `json:"fault_message,omitempty"`
+	LastError    string `json:"last_error,omitempty"` // why the connection was last lost
+}
+
+func hardwareHealth(client *splitflap.Client) HardwareHealth {
+	status := client.Status()
+	health := HardwareHealth{
+		Connected: status.Connected,
+		State:     "UNKNOWN",
+		LastError: status.LastError,
+	}
+	if hardware, err := client.Hardware(); err == nil {
+		if current, ok := hardware.Health(); ok {
+			health.State = current.State
+			health.FaultType = current.FaultType
+			health.FaultMessage = current.FaultMessage
+		}
+	}
+	return health
+}
+
+// haEntities describes a display to Home Assistant: a text entity to set its message, a select for its active
+// dashboard, a button to clear it, and sensors for what it shows and how its hardware is doing. They are keyed by the
+// discovery topic they are published to
+func (c *MQTTControl) haEntities(id string, display *splitflap.Display) map[string]haEntity {
+	nodeID := haInvalidID.ReplaceAllString(c.prefix+"_"+id, "_")
+	topic := c.prefix + "/" + id + "/"
+	device := haDevice{
+		Identifiers:  []string{nodeID},
+		Name:         "Splitflap " + id,
+		Manufacturer: "go-splitflap",
+		Model:        strconv.Itoa(display.Size.Width) + "x" + strconv.Itoa(display.Size.Height) + " splitflap display",
+	}
+	entities := make(map[string]haEntity)
+	add := func(component, object string, e haEntity) {
+		e.UniqueID = nodeID + "_" + object
+		e.AvailabilityTopic = c.statusTopic()
+		e.Device = device
+		entities[c.discoveryPrefix+"/"+component+"/"+nodeID+"/"+object+"/config"] = e
+	}
+
+	add("text", "message", haEntity{
+		Name:         "Message",
+		Icon:         "mdi:message-text",
+		StateTopic:   topic + "state",
+		CommandTopic: topic + "set",
+		Max:          display.Size.Width * display.Size.Height,
+	})
+	add("select", "dashboard", haEntity{
+		Name:            "Dashboard",
+		Icon:            "mdi:view-dashboard",
+		StateTopic:      topic + "dashboard",
+		ValueTemplate:   "{{ value if value else '" + haNoDashboard + "' }}",
+		CommandTopic:    topic + "activate",
+		CommandTemplate: "{{ '' if value == '" + haNoDashboard + "' else value }}",
+		Options:         append([]string{haNoDashboard}, slices.Sorted(maps.Keys(display.GetDashboards()))...),
+	})
+	add("button", "clear", haEntity{
+		Name:         "Clear",
+		Icon:         "mdi:eraser",
+		CommandTopic: topic + "clear",
+		PayloadPress: "CLEAR",
+	})
+	add("sensor", "state", haEntity{
+		Name:       "Showing",
+		Icon:       "mdi:text-short",
+		StateTopic: topic + "state",
+	})
+	add("sensor", "health", haEntity{
+		Name:                "Hardware health",
+		Icon:                "mdi:heart-pulse",
+		EntityCategory:      "diagnostic",
+		StateTopic:          topic + "health",
+		ValueTemplate:       "{{ value_json.state }}",
+		JSONAttributesTopic: topic + "health",
+	})
+	add("binary_sensor", "connection", haEntity{
+		Name:           "Hardware connection",
+		DeviceClass:    "connectivity",
+		EntityCategory: "diagnostic",
+		StateTopic:     topic + "health",
+		ValueTemplate:  "{{ 'ON' if value_json.connected else 'OFF' }}",
+	})
+	return entities
+}
+
+// publishDiscovery publishes the discovery configs of a display's entities, if they changed since they were last
+// published. The dashboard select's options change as dashboards are created and deleted
+func (c *MQTTControl) publishDiscovery(id string, display *splitflap.Display) {
+	if c.discoveryPrefix == "" {
+		return
+	}
+	for discoveryTopic, entity := range c.haEntities(id, display) {
+		payload, err := json.Marshal(entity)
+		if err != nil {
+			slog.Error("Failed to marshal Home Assistant discovery config", "topic", discoveryTopic, "error", err.Error())
+			continue
+		}
+		c.publish(discoveryTopic, string(payload))
+	}
+}
+
+// handleHomeAssistantStatus publishes the discovery configs again when Home Assistant comes online, in case it lost
+// the retained ones
+func (c *MQTTControl) handleHomeAssistantStatus(client mqtt.Client, msg mqtt.Message) {
+	if string(msg.Payload()) != "online" {
+		return
+	}
+	slog.Info("Home Assistant came online, publishing discovery configs")
+
+	c.lock.Lock()
+	for topic := range c.published {
+		if strings.HasPrefix(topic, c.discoveryPrefix+"/") {
+			delete(c.published, topic)
+		}
+	}
+	c.lock.Unlock()
+
+	// publishing waits for the broker, which mustn't be done while it is waiting for this handler
+	go func() {
+		for _, id := range c.hub.DisplayIDs() {
+			display, _ := c.hub.Display(id)
+			c.publishDiscovery(id, display)
+		}
+	}()
+}
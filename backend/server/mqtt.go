@@ -0,0 +1,230 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/denverquane/go-splitflap/splitflap"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+)
+
+// how long to wait for the broker to acknowledge a publish or subscription
+const mqttTimeout = 10 * time.Second
+
+// MQTTConfig is how to reach the MQTT broker that the displays are controlled through
+type MQTTConfig struct {
+	Broker   string // e.g. tcp://localhost:1883
+	Username string
+	Password string
+	Prefix   string // the first level of every topic, e.g. "splitflap" for splitflap/<display id>/set
+
+	// DiscoveryPrefix is where Home Assistant looks for discovery configs, usually "homeassistant". Empty to not publish
+	// any
+	DiscoveryPrefix string
+}
+
+// MQTTControl lets the displays be controlled over MQTT, and publishes what they show. For each display it accepts:
+//
+//	<prefix>/<id>/set       text to show, or an UpdateDisplayRequest as JSON
+//	<prefix>/<id>/activate  the name of a dashboard to activate, or nothing to deactivate the active one
+//	<prefix>/<id>/clear     anything, to deactivate the active dashboard and clear the display
+//
+// and publishes, retained, <prefix>/<id>/state with the text the display shows, <prefix>/<id>/dashboard with its
+// active dashboard and <prefix>/<id>/health with how its hardware is doing. Commands that fail are reported on
+// <prefix>/<id>/error. <prefix>/status is "online" while the backend is connected, and the broker sets it to "offline"
+// if the connection is lost. Each display is also described to Home Assistant, so it shows up there as a device
+type MQTTControl struct {
+	client          mqtt.Client
+	hub             *splitflap.Hub
+	clients         map[string]*splitflap.Client
+	prefix          string
+	discoveryPrefix string
+
+	published map[string]string // the last payload published to each topic, so unchanged state isn't published again
+	lock      sync.Mutex
+	done      chan struct{}
+}
+
+// StartMQTTControl connects to the broker, and keeps trying in the background if it can't be reached yet. clients
+// holds the hardware client of each display ID, which may be nil
+func StartMQTTControl(config MQTTConfig, hub *splitflap.Hub, clients map[string]*splitflap.Client) *MQTTControl {
+	c := &MQTTControl{
+		hub:             hub,
+		clients:         clients,
+		prefix:          strings.TrimSuffix(config.Prefix, "/"),
+		discoveryPrefix: strings.TrimSuffix(config.DiscoveryPrefix, "/"),
+		published:       make(map[string]string),
+		done:            make(chan struct{}),
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID("splitflap-"+uuid.NewString()).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetWill(c.statusTopic(), "offline", 1, true).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(client mqtt.Client, err error) {
+			slog.Warn("Lost connection to MQTT broker, reconnecting", "broker", config.Broker, "error", err.Error())
+		})
+	c.client = mqtt.NewClient(opts)
+	c.client.Connect()
+
+	for _, id := range hub.DisplayIDs() {
+		display, _ := hub.Display(id)
+		go c.publishChanges(id, display)
+	}
+	return c
+}
+
+// Stop marks the backend offline, and disconnects from the broker
+func (c *MQTTControl) Stop() {
+	close(c.done)
+	if c.client.IsConnectionOpen() {
+		c.client.Publish(c.statusTopic(), 1, true, "offline").WaitTimeout(mqttTimeout)
+	}
+	c.client.Disconnect(250)
+}
+
+func (c *MQTTControl) statusTopic() string {
+	return c.prefix + "/status"
+}
+
+// onConnect subscribes to the commands and publishes the state of every display, as neither outlives a connection
+func (c *MQTTControl) onConnect(client mqtt.Client) {
+	slog.Info("Connected to MQTT broker", "prefix", c.prefix)
+
+	filters := map[string]byte{
+		c.prefix + "/+/set":      1,
+		c.prefix + "/+/activate": 1,
+		c.prefix + "/+/clear":    1,
+	}
+	token := client.SubscribeMultiple(filters, c.handleCommand)
+	if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+		slog.Error("Failed to subscribe to MQTT commands", "error", token.Error().Error())
+	}
+	if c.discoveryPrefix != "" {
+		token = client.Subscribe(c.discoveryPrefix+"/status", 1, c.handleHomeAssistantStatus)
+		if token.WaitTimeout(mqttTimeout) && token.Error() != nil {
+			slog.Error("Failed to subscribe to Home Assistant's status", "error", token.Error().Error())
+		}
+	}
+
+	c.lock.Lock()
+	clear(c.published)
+	c.lock.Unlock()
+	c.publish(c.statusTopic(), "online")
+	for _, id := range c.hub.DisplayIDs() {
+		display, _ := c.hub.Display(id)
+		c.publishState(id, display)
+	}
+}
+
+// handleCommand carries out a command sent to one of the displays
+func (c *MQTTControl) handleCommand(client mqtt.Client, msg mqtt.Message) {
+	levels := strings.Split(strings.TrimPrefix(msg.Topic(), c.prefix+"/"), "/")
+	if len(levels) != 2 {
+		return
+	}
+	id, command := levels[0], levels[1]
+	display, ok := c.hub.Display(id)
+	if !ok {
+		slog.Warn("MQTT command for a display that does not exist", "topic", msg.Topic())
+		return
+	}
+
+	var err error
+	payload := strings.TrimSpace(string(msg.Payload()))
+	switch command {
+	case "set":
+		req := UpdateDisplayRequest{Text: strings.TrimRight(string(msg.Payload()), "\r\n")}
+		if strings.HasPrefix(payload, "{") {
+			err = json.Unmarshal([]byte(payload), &req)
+		}
+		if err == nil {
+			err = showText(display, req, "mqtt")
+		}
+	case "activate":
+		if payload == "" {
+			display.DeactivateActiveDashboard()
+		} else {
+			err = display.ActivateDashboard(payload)
+		}
+	case "clear":
+		display.DeactivateActiveDashboard()
+		display.Clear()
+	}
+	if err != nil {
+		slog.Error("Failed to carry out MQTT command", "topic", msg.Topic(), "error", err.Error())
+		c.client.Publish(c.prefix+"/"+id+"/error", 1, false, command+": "+err.Error())
+		return
+	}
+
+	// Broadcast the state change to all WebSocket clients
+	BroadcastStateChange()
+	// publishing waits for the broker, which mustn't be done while it is waiting for this handler
+	go c.publishState(id, display)
+}
+
+// publishChanges publishes the state of a display whenever it changes, and every so often in case a change was missed
+func (c *MQTTControl) publishChanges(id string, display *splitflap.Display) {
+	sub := make(chan struct{}, 1)
+	display.AddStateSubscriber(sub)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-sub:
+		case <-ticker.C:
+		}
+		c.publishState(id, display)
+	}
+}
+
+// publishState publishes the text a display shows, its active dashboard and the health of its hardware, if they
+// changed since they were last published
+func (c *MQTTControl) publishState(id string, display *splitflap.Display) {
+	c.publishDiscovery(id, display)
+	c.publish(c.prefix+"/"+id+"/state", display.GetState())
+	c.publish(c.prefix+"/"+id+"/dashboard", display.ActiveDashboard())
+
+	health, err := json.Marshal(hardwareHealth(c.clients[id]))
+	if err != nil {
+		slog.Error("Failed to marshal hardware health", "display", id, "error", err.Error())
+		return
+	}
+	c.publish(c.prefix+"/"+id+"/health", string(health))
+}
+
+// publish sends a retained payload to a topic, unless it was the last payload sent to it
+func (c *MQTTControl) publish(topic, payload string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if last, ok := c.published[topic]; ok && last == payload {
+		return
+	}
+	if !c.client.IsConnectionOpen() {
+		return
+	}
+	token := c.client.Publish(topic, 1, true, payload)
+	if !token.WaitTimeout(mqttTimeout) {
+		slog.Error("Timed out publishing to MQTT broker", "topic", topic)
+		return
+	}
+	if token.Error() != nil {
+		slog.Error("Failed to publish to MQTT broker", "topic", topic, "error", token.Error().Error())
+		return
+	}
+	c.published[topic] = payload
+}
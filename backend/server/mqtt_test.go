@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/internal/mqtttest"
+	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/splitflap"
+)
+
+func TestMQTTControl(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	published := broker.Watch("splitflap/#")
+
+	hub := splitflap.NewHub()
+	d := splitflap.NewDisplay(display.Size{Width: 4, Height: 1})
+	if err = hub.AddDisplay("lobby", d); err != nil {
+		t.Fatal(err)
+	}
+	if err = splitflap.WriteHubToFile(hub, filepath.Join(t.TempDir(), "display.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.CreateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.AddRoutineToDashboard("main", routine.Routine{
+		RoutineBase: routine.RoutineBase{Type: routine.TEXT, Size: display.Size{Width: 4, Height: 1}},
+		Routine:     &routine.TextRoutine{Text: "MAIN"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan splitflap.OutMessage)
+	state := make(chan splitflap.FlapState)
+	go d.Run(messages, state)
+	go func() {
+		for range messages {
+		}
+	}()
+
+	control := StartMQTTControl(MQTTConfig{Broker: broker.URL(), Prefix: "splitflap/"}, hub, nil)
+	stopped := false
+	defer func() {
+		if !stopped {
+			control.Stop()
+		}
+	}()
+
+	// expect waits for a payload to be published to a topic
+	expect := func(topic, payload string) {
+		t.Helper()
+		timeout := time.After(time.Second * 5)
+		for {
+			select {
+			case msg := <-published:
+				if msg.Topic == topic && msg.Payload == payload {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("expected %q to be published to %s", payload, topic)
+			}
+		}
+	}
+	expect("splitflap/status", "online")
+
+	// the display's state is published as the hardware reports it
+	state <- splitflap.FlapState{Text: "WXYZ", Moving: make([]bool, 4)}
+	expect("splitflap/lobby/state", "WXYZ")
+	if msg, ok := broker.Retained("splitflap/lobby/state"); !ok || msg.Payload != "WXYZ" {
+		t.Fatal("the state should be retained, so new subscribers see it straight away")
+	}
+
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/activate", Payload: "nope"})
+	expect("splitflap/lobby/error", "activate: dashboard does not exist")
+
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/activate", Payload: "main"})
+	expect("splitflap/lobby/dashboard", "main")
+
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/set", Payload: `{"text": "ALRT", "duration_secs": 30}`})
+	deadline := time.Now().Add(time.Second * 5)
+	for len(d.Alerts()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if alerts := d.Alerts(); len(alerts) != 1 || alerts[0].Text != "ALRT" || alerts[0].Source != "mqtt" {
+		t.Fatal("text with a duration should be shown as an alert", alerts)
+	}
+
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/set", Payload: `{"text": "TOOLONG", "duration_secs": 30}`})
+	expect("splitflap/lobby/error", "set: alert text is longer than the display")
+
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/clear"})
+	expect("splitflap/lobby/dashboard", "")
+
+	// if the backend drops off, the broker tells everyone it's offline, and it comes back online once it reconnects
+	if broker.Drop() == 0 {
+		t.Fatal("the backend should be connected")
+	}
+	expect("splitflap/status", "offline")
+	expect("splitflap/status", "online")
+
+	control.Stop()
+	stopped = true
+	expect("splitflap/status", "offline")
+	if msg, ok := broker.Retained("splitflap/status"); !ok || msg.Payload != "offline" {
+		t.Fatal("stopping should leave the backend marked offline")
+	}
+}
+
+func TestMQTTControl_homeAssistant(t *testing.T) {
+	broker, err := mqtttest.NewBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	discovery := broker.Watch("homeassistant/+/splitflap_lobby/+/config")
+
+	hub := splitflap.NewHub()
+	d := splitflap.NewDisplay(display.Size{Width: 4, Height: 1})
+	if err = hub.AddDisplay("lobby", d); err != nil {
+		t.Fatal(err)
+	}
+	if err = splitflap.WriteHubToFile(hub, filepath.Join(t.TempDir(), "display.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.CreateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	control := StartMQTTControl(MQTTConfig{Broker: broker.URL(), Prefix: "splitflap", DiscoveryPrefix: "homeassistant"}, hub, nil)
+	defer control.Stop()
+
+	// expectEntity waits for the discovery config of an entity to be published
+	expectEntity := func(topic string) haEntity {
+		t.Helper()
+		timeout := time.After(time.Second * 5)
+		for {
+			select {
+			case msg := <-discovery:
+				if msg.Topic != topic {
+					continue
+				}
+				var entity haEntity
+				if err := json.Unmarshal([]byte(msg.Payload), &entity); err != nil {
+					t.Fatal(err)
+				}
+				return entity
+			case <-timeout:
+				t.Fatal("expected a discovery config on " + topic)
+			}
+		}
+	}
+
+	text := expectEntity("homeassistant/text/splitflap_lobby/message/config")
+	if text.CommandTopic != "splitflap/lobby/set" || text.StateTopic != "splitflap/lobby/state" || text.Max != 4 {
+		t.Fatal("the text entity should set the message", text)
+	}
+	if text.AvailabilityTopic != "splitflap/status" || text.Device.Identifiers[0] != "splitflap_lobby" {
+		t.Fatal("entities should share the display's device and availability", text)
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	health, _ := broker.Retained("splitflap/lobby/health")
+	for health.Payload == "" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+		health, _ = broker.Retained("splitflap/lobby/health")
+	}
+	if health.Payload != `{"connected":false,"state":"UNKNOWN"}` {
+		t.Fatal("the health of the hardware should be published", health)
+	}
+
+	// the dashboard select's options follow the display's dashboards
+	if err = d.CreateDashboard("night"); err != nil {
+		t.Fatal(err)
+	}
+	broker.Publish(mqtttest.Message{Topic: "splitflap/lobby/activate", Payload: "night"})
+	dashboard := expectEntity("homeassistant/select/splitflap_lobby/dashboard/config")
+	for !slices.Contains(dashboard.Options, "night") {
+		dashboard = expectEntity("homeassistant/select/splitflap_lobby/dashboard/config")
+	}
+	if !slices.Equal(dashboard.Options, []string{haNoDashboard, "main", "night"}) || dashboard.CommandTopic != "splitflap/lobby/activate" {
+		t.Fatal("the select should activate dashboards", dashboard)
+	}
+
+	// everything is published again when Home Assistant restarts
+	broker.Publish(mqtttest.Message{Topic: "homeassistant/status", Payload: "online"})
+	expectEntity("homeassistant/button/splitflap_lobby/clear/config")
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+// ActivePlaylistResponse describes the playlist that is currently running, and which step it is on
+type ActivePlaylistResponse struct {
+	Playlist string `json:"playlist"`
+	Step     int    `json:"step"`
+}
+
+// SetupPlaylistHandlers registers all playlist-related routes
+func SetupPlaylistHandlers(r chi.Router, display *splitflap.Display) {
+	r.Get("/", getAllPlaylists(display))
+	r.Get("/active", getActivePlaylist(display))
+	r.Post("/stop", stopPlaylist(display))
+	r.Post("/{playlistName}", createOrUpdatePlaylist(display))
+	r.Delete("/{playlistName}", deletePlaylist(display))
+	r.Post("/{playlistName}/start", startPlaylist(display))
+}
+
+// getAllPlaylists returns all playlists
+func getAllPlaylists(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(display.GetPlaylists())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func getActivePlaylist(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, step := display.ActivePlaylist()
+		bytes, err := json.Marshal(ActivePlaylistResponse{
+			Playlist: name,
+			Step:     step,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func createOrUpdatePlaylist(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		playlistName := chi.URLParam(r, "playlistName")
+		if playlistName == "" {
+			http.Error(w, "empty playlist name is not allowed", http.StatusBadRequest)
+			return
+		}
+
+		var playlist splitflap.Playlist
+		err := json.NewDecoder(r.Body).Decode(&playlist)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = display.CreateOrUpdatePlaylist(playlistName, &playlist)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(playlistName))
+	}
+}
+
+// deletePlaylist removes a playlist
+func deletePlaylist(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		playlistName := chi.URLParam(r, "playlistName")
+		err := display.DeletePlaylist(playlistName)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(playlistName))
+	}
+}
+
+// startPlaylist starts rotating through the dashboards of a playlist
+func startPlaylist(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		playlistName := chi.URLParam(r, "playlistName")
+		if _, ok := display.GetPlaylists()[playlistName]; !ok {
+			http.Error(w, "no playlist found with that name", http.StatusBadRequest)
+			return
+		}
+		err := display.StartPlaylist(playlistName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Broadcast the state change to all WebSocket clients
+		BroadcastStateChange()
+
+		w.Write([]byte(playlistName))
+	}
+}
+
+// stopPlaylist stops the running playlist, leaving its current dashboard active
+func stopPlaylist(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		display.StopPlaylist()
+
+		// Broadcast the state change to all WebSocket clients
+		BroadcastStateChange()
+
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
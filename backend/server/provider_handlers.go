@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+// SetupProviderHandlers registers all provider-related routes. Providers are shared by every display, so these live
+// outside of any display's routes
+func SetupProviderHandlers(r chi.Router, hub *splitflap.Hub) {
+	r.Get("/", getAllProviders(hub))
+	r.Get("/types", getAllProviderTypes())
+	r.Post("/{providerName}", createOrUpdateProvider(hub))
+	r.Delete("/{providerName}", deleteProvider(hub))
+	r.Post("/{providerName}/start", startProvider(hub))
+	r.Post("/{providerName}/stop", stopProvider(hub))
+	r.Get("/{providerName}/values", getProviderValues(hub))
+}
+
+// ProviderTypeInfo holds the full information about a provider type, including its parameters
+type ProviderTypeInfo struct {
+	Parameters []provider.Parameter `json:"parameters"`
+	Config     interface{}          `json:"config"`
+}
+
+// getAllProviders returns every provider, with its config and whether it is running
+func getAllProviders(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(hub.GetProviders())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// getAllProviderTypes returns all available provider types with their parameters
+func getAllProviderTypes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := make(map[string]ProviderTypeInfo)
+		for providerType, providerInstance := range provider.AllProviders {
+			response[string(providerType)] = ProviderTypeInfo{
+				Parameters: providerInstance.Parameters(),
+				Config:     providerInstance,
+			}
+		}
+
+		bytes, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+// createOrUpdateProvider adds a provider, or replaces the config of an existing one
+func createOrUpdateProvider(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerName := chi.URLParam(r, "providerName")
+
+		var prov provider.Provider
+		err := json.NewDecoder(r.Body).Decode(&prov)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = hub.CreateOrUpdateProvider(providerName, &prov)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(providerName))
+	}
+}
+
+// deleteProvider stops and removes a provider that no routine uses
+func deleteProvider(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerName := chi.URLParam(r, "providerName")
+
+		err := hub.DeleteProvider(providerName)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(providerName))
+	}
+}
+
+// startProvider starts a stopped provider
+func startProvider(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerName := chi.URLParam(r, "providerName")
+
+		err := hub.StartProvider(providerName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(providerName))
+	}
+}
+
+// stopProvider stops a running provider
+func stopProvider(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providerName := chi.URLParam(r, "providerName")
+
+		err := hub.StopProvider(providerName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(providerName))
+	}
+}
+
+// getProviderValues returns the values a provider currently supplies to routines
+func getProviderValues(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values, err := hub.ProviderValues(chi.URLParam(r, "providerName"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		bytes, err := json.Marshal(values)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/denverquane/go-splitflap/display"
 	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/transition"
 	"github.com/go-chi/chi/v5"
 	"net/http"
 )
@@ -45,3 +46,20 @@ func getAllRoutines() http.HandlerFunc {
 		respondJSON(w, bytes)
 	}
 }
+
+// SetupTransitionHandlers registers all transition-related routes
+func SetupTransitionHandlers(r chi.Router) {
+	r.Get("/", getAllTransitions())
+}
+
+// getAllTransitions returns every transition effect, with its description
+func getAllTransitions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(transition.All)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+// ActiveScheduleResponse describes the schedule rule currently in effect, and any manual override of the schedule
+type ActiveScheduleResponse struct {
+	Schedule      string    `json:"schedule"`
+	OverrideUntil time.Time `json:"override_until"`
+}
+
+// ScheduleTimezoneRequest represents the request body for changing the timezone schedules are evaluated in
+type ScheduleTimezoneRequest struct {
+	Timezone string `json:"timezone"`
+}
+
+// SetupScheduleHandlers registers all schedule-related routes
+func SetupScheduleHandlers(r chi.Router, display *splitflap.Display) {
+	r.Get("/", getAllSchedules(display))
+	r.Get("/active", getActiveSchedule(display))
+	r.Get("/timezone", getScheduleTimezone(display))
+	r.Post("/timezone", updateScheduleTimezone(display))
+	r.Post("/{scheduleName}", createOrUpdateSchedule(display))
+	r.Delete("/{scheduleName}", deleteSchedule(display))
+}
+
+// getAllSchedules returns all schedule rules
+func getAllSchedules(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(display.GetSchedules())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func getActiveSchedule(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, overrideUntil := display.ActiveSchedule()
+		bytes, err := json.Marshal(ActiveScheduleResponse{
+			Schedule:      name,
+			OverrideUntil: overrideUntil,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func getScheduleTimezone(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(ScheduleTimezoneRequest{Timezone: display.GetScheduleTimezone()})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
+}
+
+func updateScheduleTimezone(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ScheduleTimezoneRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = display.SetScheduleTimezone(req.Timezone)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		respondJSON(w, []byte(`{"status":"ok"}`))
+	}
+}
+
+func createOrUpdateSchedule(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleName := chi.URLParam(r, "scheduleName")
+		if scheduleName == "" {
+			http.Error(w, "empty schedule name is not allowed", http.StatusBadRequest)
+			return
+		}
+
+		var schedule splitflap.Schedule
+		err := json.NewDecoder(r.Body).Decode(&schedule)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = display.CreateOrUpdateSchedule(scheduleName, &schedule)
+		if err != nil {
+			slog.Error(err.Error())
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(scheduleName))
+	}
+}
+
+// deleteSchedule removes a schedule rule
+func deleteSchedule(display *splitflap.Display) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleName := chi.URLParam(r, "scheduleName")
+		err := display.DeleteSchedule(scheduleName)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+
+		w.Write([]byte(scheduleName))
+	}
+}
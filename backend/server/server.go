@@ -1,49 +1,137 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/denverquane/go-splitflap/splitflap"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
-// Global WebSocket manager to broadcast updates
-var WebSocketMgr *WebSocketManager
+// how long requests in flight get to finish when the server shuts down
+const shutdownTimeout = 5 * time.Second
 
-// Run initializes and starts the HTTP server
-func Run(port string, display *splitflap.Display) error {
+// Global WebSocket managers to broadcast updates, one per display ID
+var WebSocketMgrs = make(map[string]*WebSocketManager)
+
+// Run initializes and starts the HTTP server, and serves until the process is interrupted or terminated. clients holds
+// the hardware client of each display ID; a display's client may be nil when running without hardware. The displays
+// are also controlled over MQTT if mqttConfig has a broker
+func Run(port string, hub *splitflap.Hub, clients map[string]*splitflap.Client, mqttConfig MQTTConfig) error {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
-	// Initialize WebSocket manager
-	WebSocketMgr = NewWebSocketManager(display)
+	r.Route("/routines", func(r chi.Router) {
+		SetupRoutineHandlers(r)
+	})
+
+	r.Route("/transitions", func(r chi.Router) {
+		SetupTransitionHandlers(r)
+	})
+
+	r.Route("/providers", func(r chi.Router) {
+		SetupProviderHandlers(r, hub)
+	})
+
+	r.Get("/displays", listDisplays(hub))
+
+	// Every display gets its own set of routes and WebSocket manager
+	for _, id := range hub.DisplayIDs() {
+		display, _ := hub.Display(id)
+		client := clients[id]
+		WebSocketMgrs[id] = NewWebSocketManager(display, client)
 
-	// Set up API routes
+		wsManager := WebSocketMgrs[id]
+		r.Route("/displays/"+id, func(r chi.Router) {
+			SetupPerDisplayRoutes(r, display, client, wsManager)
+		})
+
+		// Start periodic state broadcast (every 5 seconds)
+		go startPeriodicBroadcast(wsManager)
+	}
+
+	// The un-namespaced routes serve the default display, for clients that only know about a single display
+	defaultID := hub.DefaultDisplay()
+	defaultDisplay, _ := hub.Display(defaultID)
+	SetupPerDisplayRoutes(r, defaultDisplay, clients[defaultID], WebSocketMgrs[defaultID])
+
+	if mqttConfig.Broker != "" {
+		control := StartMQTTControl(mqttConfig, hub, clients)
+		// marks the backend offline on the way out, rather than leaving it to the broker to notice
+		defer control.Stop()
+		slog.Info("MQTT control enabled", "broker", mqttConfig.Broker, "topics", mqttConfig.Prefix+"/{id}/...")
+	}
+
+	slog.Info("Server started on port "+port, "displays", hub.DisplayIDs(), "default", defaultID)
+	slog.Info("WebSocket endpoint available at ws://localhost:" + port + "/displays/{id}/ws")
+
+	// Start the server
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	failed := make(chan error, 1)
+	go func() {
+		failed <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// SetupPerDisplayRoutes registers every route that belongs to a single display
+func SetupPerDisplayRoutes(r chi.Router, display *splitflap.Display, client *splitflap.Client, wsManager *WebSocketManager) {
 	r.Route("/display", func(r chi.Router) {
-		SetupDisplayHandlers(r, display)
+		SetupDisplayHandlers(r, display, client)
 	})
 
-	r.Route("/routines", func(r chi.Router) {
-		SetupRoutineHandlers(r)
+	r.Route("/hardware", func(r chi.Router) {
+		SetupHardwareHandlers(r, client)
 	})
 
 	r.Route("/dashboards", func(r chi.Router) {
 		SetupDashboardHandlers(r, display)
 	})
 
-	// Set up WebSocket route
-	SetupWebSocketRoutes(r, WebSocketMgr)
+	r.Route("/playlists", func(r chi.Router) {
+		SetupPlaylistHandlers(r, display)
+	})
+
+	r.Route("/schedules", func(r chi.Router) {
+		SetupScheduleHandlers(r, display)
+	})
 
-	// Start periodic state broadcast (every 5 seconds)
-	go startPeriodicBroadcast(WebSocketMgr)
+	r.Route("/alerts", func(r chi.Router) {
+		SetupAlertHandlers(r, display)
+	})
 
-	slog.Info("Server started on port " + port)
-	slog.Info("WebSocket endpoint available at ws://localhost:" + port + "/ws")
+	// Set up WebSocket route
+	SetupWebSocketRoutes(r, wsManager)
+}
 
-	// Start the server
-	return http.ListenAndServe(":"+port, r)
+// listDisplays returns the IDs of every display managed by this backend
+func listDisplays(hub *splitflap.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bytes, err := json.Marshal(hub.DisplayIDs())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, bytes)
+	}
 }
 
 // startPeriodicBroadcast periodically sends state updates to all clients
@@ -59,9 +147,18 @@ func startPeriodicBroadcast(wsManager *WebSocketManager) {
 	}
 }
 
-// BroadcastStateChange can be called to immediately broadcast state to all clients
+// BroadcastStateChange can be called to immediately broadcast state to all clients, of every display
 func BroadcastStateChange() {
-	if WebSocketMgr != nil {
-		WebSocketMgr.BroadcastState()
+	for _, wsManager := range WebSocketMgrs {
+		wsManager.BroadcastState()
+	}
+}
+
+// errorStatus is the status to respond with when a change fails: 409 if the config file was changed on disk and the
+// change can be made once it has been reloaded, otherwise status
+func errorStatus(err error, status int) int {
+	if errors.Is(err, splitflap.ErrConfigChanged) {
+		return http.StatusConflict
 	}
+	return status
 }
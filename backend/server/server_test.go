@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/splitflap"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestServer_concurrentRequests(t *testing.T) {
+	hub := splitflap.NewHub()
+	d := splitflap.NewDisplay(display.Size{Width: 4, Height: 1})
+	d.PollRate = 5
+	if err := hub.AddDisplay(splitflap.DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := splitflap.WriteHubToFile(hub, filepath.Join(t.TempDir(), "display.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan splitflap.OutMessage)
+	go d.Run(messages, make(chan splitflap.FlapState))
+	go func() {
+		for range messages {
+		}
+	}()
+
+	r := chi.NewRouter()
+	SetupPerDisplayRoutes(r, d, nil, NewWebSocketManager(d, nil))
+
+	do := func(method, path, body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			dashboard := "/dashboards/dash" + strconv.Itoa(i)
+			playlist := "/playlists/list" + strconv.Itoa(i)
+			for j := 0; j < 25; j++ {
+				requests := []struct {
+					method, path, body string
+				}{
+					{http.MethodPost, dashboard, `[{"type": "TEXT", "size": {"width": 4, "height": 1}, "config": {"text": "HI"}}]`},
+					{http.MethodPost, dashboard + "/activate", ""},
+					{http.MethodPost, "/display/update", `{"text": "ABCD"}`},
+					{http.MethodPost, playlist, `{"steps": [{"dashboard": "dash` + strconv.Itoa(i) + `", "duration_secs": 1}]}`},
+					{http.MethodPost, playlist + "/start", ""},
+					{http.MethodPost, "/display/motion", `{"skip_slow_frames": true}`},
+					{http.MethodGet, "/dashboards/", ""},
+					{http.MethodGet, "/playlists/", ""},
+					{http.MethodGet, "/schedules/", ""},
+					{http.MethodGet, "/display/state", ""},
+					{http.MethodGet, "/display/translations", ""},
+					{http.MethodPost, "/playlists/stop", ""},
+					{http.MethodDelete, playlist, ""},
+					{http.MethodDelete, dashboard, ""},
+				}
+				// the goroutines get in each other's way, e.g. by activating a dashboard another one is deleting, so only
+				// server errors are failures
+				for _, req := range requests {
+					if code := do(req.method, req.path, req.body); code >= http.StatusInternalServerError {
+						t.Error(req.method, req.path, "returned", code)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestServer_configChanged(t *testing.T) {
+	hub := splitflap.NewHub()
+	d := splitflap.NewDisplay(display.Size{Width: 4, Height: 1})
+	if err := hub.AddDisplay(splitflap.DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "display.json")
+	if err := splitflap.WriteHubToFile(hub, path); err != nil {
+		t.Fatal(err)
+	}
+	// an edit on disk that hasn't been reloaded yet
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	SetupPerDisplayRoutes(r, d, nil, NewWebSocketManager(d, nil))
+	for _, req := range []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/dashboards/main", `[]`},
+		{http.MethodPost, "/schedules/night", `{"start": "22:00"}`},
+		{http.MethodPost, "/display/motion", `{"skip_slow_frames": true}`},
+		{http.MethodPost, "/display/translations", `{}`},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if w.Code != http.StatusConflict {
+			t.Error(req.method, req.path, "returned", w.Code, "instead of a conflict")
+		}
+	}
+	if len(d.GetDashboards()) != 0 || len(d.GetSchedules()) != 0 {
+		t.Fatal("nothing should change while the file has unreloaded changes")
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
 	"github.com/denverquane/go-splitflap/splitflap"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
@@ -21,13 +22,23 @@ type WebSocketManager struct {
 
 	// Reference to the display for accessing state
 	display *splitflap.Display
+
+	// Reference to the hardware client for connection status (nil when running without hardware)
+	client *splitflap.Client
 }
 
 // DisplayState represents the current state of the display
 type DisplayState struct {
-	ActiveDashboard string    `json:"activeDashboard"`
-	State           string    `json:"state"`
-	CurrentTime     time.Time `json:"currentTime"`
+	ActiveDashboard string           `json:"activeDashboard"`
+	ActivePlaylist  string           `json:"activePlaylist"`
+	PlaylistStep    int              `json:"playlistStep"`
+	ActiveSchedule  string           `json:"activeSchedule"`
+	ActiveAlert     *splitflap.Alert `json:"activeAlert,omitempty"`
+	State           string           `json:"state"`
+	CurrentTime     time.Time        `json:"currentTime"`
+
+	Connection usb_serial.ConnectionStatus `json:"connection"`
+	Health     *usb_serial.Health          `json:"health,omitempty"`
 }
 
 // upgrader is used to upgrade HTTP connections to WebSocket connections
@@ -39,20 +50,23 @@ var upgrader = websocket.Upgrader{
 }
 
 // NewWebSocketManager creates a new WebSocketManager
-func NewWebSocketManager(display *splitflap.Display) *WebSocketManager {
-	sub := make(chan struct{})
-	display.SetStateSubscriber(sub)
+func NewWebSocketManager(display *splitflap.Display, client *splitflap.Client) *WebSocketManager {
+	wsm := &WebSocketManager{
+		clients: make(map[*websocket.Conn]bool),
+		display: display,
+		client:  client,
+	}
+
+	sub := make(chan struct{}, 1)
+	display.AddStateSubscriber(sub)
 
 	go func() {
 		for range sub {
-			BroadcastStateChange()
+			wsm.BroadcastState()
 		}
 	}()
 
-	return &WebSocketManager{
-		clients: make(map[*websocket.Conn]bool),
-		display: display,
-	}
+	return wsm
 }
 
 // HandleWebSocket handles incoming WebSocket connections
@@ -152,10 +166,28 @@ func (wsm *WebSocketManager) BroadcastState() {
 
 // getCurrentState gets the current state of the display
 func (wsm *WebSocketManager) getCurrentState() DisplayState {
+	playlist, step := wsm.display.ActivePlaylist()
+	schedule, _ := wsm.display.ActiveSchedule()
+	var health *usb_serial.Health
+	if hardware, err := wsm.client.Hardware(); err == nil {
+		if current, ok := hardware.Health(); ok {
+			health = &current
+		}
+	}
+	var activeAlert *splitflap.Alert
+	if alert, ok := wsm.display.ActiveAlert(); ok {
+		activeAlert = &alert
+	}
 	return DisplayState{
 		ActiveDashboard: wsm.display.ActiveDashboard(),
+		ActiveAlert:     activeAlert,
+		ActivePlaylist:  playlist,
+		PlaylistStep:    step,
+		ActiveSchedule:  schedule,
 		State:           wsm.display.GetState(),
 		CurrentTime:     time.Now(),
+		Connection:      wsm.client.Status(),
+		Health:          health,
 	}
 }
 
@@ -0,0 +1,143 @@
+package splitflap
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/denverquane/go-splitflap/transition"
+)
+
+// MaxAlertTTLSecs bounds how long a single alert can hold the display
+const MaxAlertTTLSecs = 24 * 60 * 60
+
+// Alert is a message that temporarily covers whatever the display is showing. Alerts are layered: the highest priority
+// alert is shown, alerts of equal priority are shown in the order they arrived, and once there are no alerts left the
+// dashboard underneath is shown again
+type Alert struct {
+	ID         string            `json:"id"`
+	Text       string            `json:"text"`
+	Priority   int               `json:"priority"`
+	TTLSecs    int               `json:"ttl_secs"` // how long the alert is shown for, not counting time spent preempted or queued
+	Source     string            `json:"source"`   // where the alert came from, e.g. "api" or "webhook"
+	Transition transition.Effect `json:"transition,omitempty"`
+	Created    time.Time         `json:"created"`
+	Expires    time.Time         `json:"expires"` // zero unless the alert is currently being shown
+
+	remaining time.Duration
+	seq       uint64
+}
+
+func (a *Alert) Check(size int) error {
+	if a.Text == "" {
+		return errors.New("alert text cannot be empty")
+	}
+	if len([]rune(a.Text)) > size {
+		return errors.New("alert text is longer than the display")
+	}
+	if a.TTLSecs < 1 || a.TTLSecs > MaxAlertTTLSecs {
+		return errors.New("alert ttl_secs must be between 1 and " + strconv.Itoa(MaxAlertTTLSecs))
+	}
+	return transition.Check(a.Transition)
+}
+
+// PushAlert validates and queues an alert, returning its ID. It preempts the alert being shown if it has a higher
+// priority
+func (d *Display) PushAlert(alert Alert) (string, error) {
+	if err := alert.Check(d.Size.Width * d.Size.Height); err != nil {
+		return "", err
+	}
+
+	d.alertLock.Lock()
+	defer d.alertLock.Unlock()
+
+	d.alertSeq++
+	alert.seq = d.alertSeq
+	alert.ID = strconv.FormatUint(alert.seq, 10)
+	alert.Created = time.Now()
+	alert.Expires = time.Time{}
+	alert.remaining = time.Duration(alert.TTLSecs) * time.Second
+
+	// keep the alerts ordered by priority, then by arrival, so the alert to show is always first
+	idx, _ := slices.BinarySearchFunc(d.alerts, &alert, func(a, b *Alert) int {
+		if a.Priority != b.Priority {
+			return b.Priority - a.Priority
+		}
+		return int(a.seq) - int(b.seq)
+	})
+	d.alerts = slices.Insert(d.alerts, idx, &alert)
+	return alert.ID, nil
+}
+
+// Alerts returns every pending alert, with the alert that is being (or is about to be) shown first
+func (d *Display) Alerts() []Alert {
+	d.alertLock.Lock()
+	defer d.alertLock.Unlock()
+
+	alerts := make([]Alert, len(d.alerts))
+	for i, a := range d.alerts {
+		alerts[i] = *a
+	}
+	return alerts
+}
+
+// ActiveAlert returns the alert that is currently covering the display, if any
+func (d *Display) ActiveAlert() (Alert, bool) {
+	d.alertLock.Lock()
+	defer d.alertLock.Unlock()
+
+	for _, a := range d.alerts {
+		if a.ID == d.shownAlert {
+			return *a, true
+		}
+	}
+	return Alert{}, false
+}
+
+func (d *Display) CancelAlert(id string) error {
+	d.alertLock.Lock()
+	defer d.alertLock.Unlock()
+
+	for i, a := range d.alerts {
+		if a.ID == id {
+			d.alerts = slices.Delete(d.alerts, i, i+1)
+			return nil
+		}
+	}
+	return errors.New("alert with that id doesn't exist")
+}
+
+// updateAlerts expires the alert being shown once its time is up, and switches to whichever alert should be shown
+// next. It returns the alert to show, whether that changed since the last call, and whether any alert is being shown
+// at all
+func (d *Display) updateAlerts(now time.Time) (Alert, bool, bool) {
+	d.alertLock.Lock()
+	defer d.alertLock.Unlock()
+
+	if len(d.alerts) > 0 && d.alerts[0].ID == d.shownAlert && !now.Before(d.alerts[0].Expires) {
+		d.alerts = d.alerts[1:]
+	}
+
+	if len(d.alerts) == 0 {
+		changed := d.shownAlert != ""
+		d.shownAlert = ""
+		return Alert{}, changed, false
+	}
+
+	next := d.alerts[0]
+	if next.ID == d.shownAlert {
+		return *next, false, true
+	}
+
+	// the alert that was shown was preempted (or cancelled); if it's still queued it gets the rest of its time later
+	for _, a := range d.alerts {
+		if a.ID == d.shownAlert {
+			a.remaining = a.Expires.Sub(now)
+			a.Expires = time.Time{}
+		}
+	}
+	next.Expires = now.Add(next.remaining)
+	d.shownAlert = next.ID
+	return *next, true, true
+}
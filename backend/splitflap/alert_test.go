@@ -0,0 +1,97 @@
+package splitflap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+)
+
+func pushTestAlert(t *testing.T, d *Display, text string, priority, ttlSecs int) string {
+	id, err := d.PushAlert(Alert{Text: text, Priority: priority, TTLSecs: ttlSecs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestAlert_Check(t *testing.T) {
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	if _, err := d.PushAlert(Alert{Text: "TOO LONG", TTLSecs: 5}); err == nil {
+		t.Fatal("alert longer than the display should be rejected")
+	}
+	if _, err := d.PushAlert(Alert{Text: "OK"}); err == nil {
+		t.Fatal("alert without a ttl should be rejected")
+	}
+	if _, err := d.PushAlert(Alert{TTLSecs: 5}); err == nil {
+		t.Fatal("alert without text should be rejected")
+	}
+}
+
+func TestDisplay_updateAlerts_priorities(t *testing.T) {
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	now := time.Now()
+
+	if _, changed, showing := d.updateAlerts(now); changed || showing {
+		t.Fatal("nothing should be shown without alerts")
+	}
+
+	pushTestAlert(t, d, "LOW", 0, 10)
+	alert, changed, showing := d.updateAlerts(now)
+	if alert.Text != "LOW" || !changed || !showing {
+		t.Fatal("first alert should be shown")
+	}
+
+	// 4 seconds into the low priority alert, a higher priority one preempts it
+	now = now.Add(time.Second * 4)
+	pushTestAlert(t, d, "HIGH", 5, 5)
+	pushTestAlert(t, d, "NEXT", 5, 5)
+	if alert, changed, _ = d.updateAlerts(now); alert.Text != "HIGH" || !changed {
+		t.Fatal("higher priority alert should preempt the one being shown")
+	}
+	alerts := d.Alerts()
+	if len(alerts) != 3 || alerts[1].Text != "NEXT" || alerts[2].Text != "LOW" {
+		t.Fatal("alerts should be ordered by priority, then arrival", alerts)
+	}
+
+	now = now.Add(time.Second * 5)
+	if alert, _, _ = d.updateAlerts(now); alert.Text != "NEXT" {
+		t.Fatal("equal priority alerts should be shown in order")
+	}
+	now = now.Add(time.Second * 5)
+	if alert, _, _ = d.updateAlerts(now); alert.Text != "LOW" {
+		t.Fatal("preempted alert should resume")
+	}
+
+	// the low priority alert only has the 6 seconds it didn't get to show left
+	now = now.Add(time.Second * 5)
+	if _, changed, showing = d.updateAlerts(now); changed || !showing {
+		t.Fatal("resumed alert should still be showing")
+	}
+	now = now.Add(time.Second)
+	if _, changed, showing = d.updateAlerts(now); !changed || showing {
+		t.Fatal("resumed alert should expire once its remaining time is up")
+	}
+}
+
+func TestDisplay_CancelAlert(t *testing.T) {
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	now := time.Now()
+
+	first := pushTestAlert(t, d, "ONE", 0, 10)
+	pushTestAlert(t, d, "TWO", 0, 10)
+	d.updateAlerts(now)
+
+	if err := d.CancelAlert(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CancelAlert(first); err == nil {
+		t.Fatal("cancelling an alert twice should fail")
+	}
+	if alert, changed, _ := d.updateAlerts(now); alert.Text != "TWO" || !changed {
+		t.Fatal("next alert should be shown once the shown alert is cancelled")
+	}
+	if alert, ok := d.ActiveAlert(); !ok || alert.Text != "TWO" || alert.Expires.IsZero() {
+		t.Fatal("active alert should be the one being shown")
+	}
+}
@@ -4,16 +4,26 @@ import (
 	"errors"
 	gen "github.com/denverquane/go-splitflap/serdiev/generated"
 	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+	"github.com/denverquane/go-splitflap/transition"
 	"log/slog"
+	"time"
 )
 
 type OutMessage struct {
 	payload string
+	frames  []transition.Frame // if set, the payload is reached through these frames instead of being sent directly
+	hold    bool               // send modules that don't change as usb_serial.HoldCharacter
+	source  string             // what the text came from, to label the wear it causes
 }
 
+// how long to wait before sending text the hardware refused again
+const refusedRetryTime = time.Second * 5
+
 type Client struct {
 	serial   *usb_serial.Splitflap
-	lastSent string
+	lastSent string    // the last text the hardware accepted
+	sentAt   time.Time // when lastSent was accepted
+	refused  string    // text the hardware refused, e.g. during a power fault, which is sent again until it is accepted
 }
 
 func NewSplitflapClient() Client {
@@ -23,12 +33,22 @@ func NewSplitflapClient() Client {
 	}
 }
 
-func (c *Client) Connect(port string, notify func(state *gen.SplitflapState)) error {
-	connection := usb_serial.NewSerialConnectionOnPort(port)
+func (c *Client) Connect(port string, notify func(state *gen.SplitflapState), recorder *usb_serial.Recorder) error {
+	var connection usb_serial.SerialConnection
+	if usb_serial.IsTCPPort(port) {
+		if tcp := usb_serial.NewTCPConnection(port); tcp != nil {
+			connection = tcp
+		}
+	} else if serial := usb_serial.NewSerialConnectionOnPort(port); serial != nil {
+		connection = serial
+	}
 	if connection == nil {
-		return errors.New("couldn't connect over USB")
+		return errors.New("couldn't connect to splitflap on port " + port)
 	}
 	sf := usb_serial.NewSplitflap(connection, notify, 0)
+	if recorder != nil {
+		sf.SetRecorder(recorder)
+	}
 	sf.Start()
 
 	c.serial = sf
@@ -41,21 +61,155 @@ func (c *Client) SetSerial(sf *usb_serial.Splitflap) {
 	c.serial = sf
 }
 
+// Status returns the status of the hardware connection. A nil Client (software-only mode) is always disconnected
+func (c *Client) Status() usb_serial.ConnectionStatus {
+	if c == nil || c.serial == nil {
+		return usb_serial.ConnectionStatus{}
+	}
+	return c.serial.Status()
+}
+
+// Hardware returns the underlying serial connection to the splitflap, for sending commands directly to its modules
+func (c *Client) Hardware() (*usb_serial.Splitflap, error) {
+	if c == nil || c.serial == nil {
+		return nil, errors.New("no hardware connection, running in software-only mode")
+	}
+	return c.serial, nil
+}
+
+// Close stops the connection to the hardware, saving the wear counted for its modules
+func (c *Client) Close() {
+	if c == nil || c.serial == nil {
+		return
+	}
+	c.serial.Stop()
+}
+
+// SetStatusHandler registers a function that is called whenever the hardware connects or disconnects
+func (c *Client) SetStatusHandler(handler func(status usb_serial.ConnectionStatus)) {
+	if c.serial != nil {
+		c.serial.SetStatusHandler(handler)
+	}
+}
+
+// SetWearTracker counts the wear of the hardware's modules from now on
+func (c *Client) SetWearTracker(tracker *usb_serial.WearTracker) {
+	if c.serial != nil {
+		c.serial.SetWearTracker(tracker)
+	}
+}
+
+// SetHealthHandler registers a function that is called whenever the hardware's power supervisor state changes
+func (c *Client) SetHealthHandler(handler func(health usb_serial.Health)) {
+	if c.serial != nil {
+		c.serial.SetHealthHandler(handler)
+	}
+}
+
 func (c *Client) Run(outmessages <-chan OutMessage) {
 	if c.serial == nil {
 		slog.Error("Tried to start Client with a nil serial connection, exiting")
 		return
 	}
+
+	// frames of the transition in progress, which are sent one by one as their delays pass, and the text they lead to
+	var frames []transition.Frame
+	var target string
+	nextFrame := time.NewTimer(0)
+	nextFrame.Stop()
+	retry := time.NewTimer(0)
+	retry.Stop()
+
 	for {
 		select {
 		case msg := <-outmessages:
-			if msg.payload != c.lastSent {
-				c.lastSent = msg.payload
-				err := c.serial.SetTextWithMovement(msg.payload, usb_serial.ForceMovementNone)
-				if err != nil {
-					slog.Error(err.Error())
+			// a new message interrupts any transition that is still in progress, and replaces any refused text
+			interrupted := len(frames) > 0
+			frames, target = msg.frames, msg.payload
+			nextFrame.Stop()
+			retry.Stop()
+			c.refused = ""
+			c.serial.SetWearSource(msg.source)
+			if len(frames) > 0 {
+				nextFrame.Reset(frames[0].Delay)
+			} else if msg.payload != c.lastSent || interrupted {
+				text := msg.payload
+				// after a reconnect or a fault the modules may not show lastSent any more, so nothing can be held
+				if msg.hold && !interrupted && !c.disturbedSince(c.sentAt) {
+					text = holdUnchanged(c.lastSent, msg.payload)
 				}
+				c.deliver(msg.payload, text, usb_serial.ForceMovementNone, retry)
+			}
+
+		case <-nextFrame.C:
+			if len(frames) == 0 {
+				break
+			}
+			frame := frames[0]
+			frames = frames[1:]
+			if len(frames) > 0 {
+				c.send(frame.Text, frame.Movement)
+				nextFrame.Reset(frames[0].Delay)
+			} else {
+				c.deliver(target, frame.Text, frame.Movement, retry)
+			}
+
+		case <-retry.C:
+			if c.refused != "" {
+				c.deliver(c.refused, c.refused, usb_serial.ForceMovementNone, retry)
 			}
 		}
 	}
 }
+
+// deliver sends text that shows payload on the hardware. If the hardware refuses it, payload is sent again once retry
+// fires, so text refused during a fault isn't taken as shown
+func (c *Client) deliver(payload, text string, movement usb_serial.ForceMovement, retry *time.Timer) {
+	if !c.send(text, movement) {
+		c.refused = payload
+		retry.Reset(refusedRetryTime)
+		return
+	}
+	c.lastSent = payload
+	c.sentAt = time.Now()
+	c.refused = ""
+}
+
+// disturbedSince is true if the hardware lost its connection or reported a fault since t, either of which can leave
+// its modules somewhere other than where they were last sent
+func (c *Client) disturbedSince(t time.Time) bool {
+	if c.serial.Status().Since.After(t) {
+		return true
+	}
+	history := c.serial.HealthHistory()
+	for i := len(history) - 1; i >= 0 && history[i].Received.After(t); i-- {
+		if history[i].Faulted() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) send(text string, movement usb_serial.ForceMovement) bool {
+	err := c.serial.SetTextWithMovement(text, movement)
+	if err != nil {
+		slog.Error(err.Error())
+		return false
+	}
+	return true
+}
+
+// holdUnchanged replaces the modules of next that are the same as in prev with usb_serial.HoldCharacter
+func holdUnchanged(prev, next string) string {
+	p := []rune(prev)
+	text := []rune(next)
+	if len(p) != len(text) {
+		return next
+	}
+	for i := range text {
+		if text[i] == p[i] {
+			text[i] = rune(usb_serial.HoldCharacter)
+		}
+	}
+	return string(text)
+}
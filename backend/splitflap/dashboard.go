@@ -6,12 +6,14 @@ import (
 	"github.com/denverquane/go-splitflap/display"
 	"github.com/denverquane/go-splitflap/provider"
 	"github.com/denverquane/go-splitflap/routine"
-	"reflect"
+	"github.com/denverquane/go-splitflap/transition"
+	"strconv"
 	"time"
 )
 
 type Dashboard struct {
-	Routines []*routine.Routine `json:"routines"`
+	Routines   []*routine.Routine `json:"routines"`
+	Transition transition.Effect  `json:"transition,omitempty"` // how the display changes over to this dashboard
 }
 
 type DashboardMessage struct {
@@ -24,25 +26,28 @@ type DashboardMessage struct {
 // of yet when they're in JSON form) and convert them dynamically once we know the type field
 func (d *Dashboard) UnmarshalJSON(data []byte) error {
 	aux := &struct {
-		Routines []routine.RoutineJSON `json:"routines"`
+		Routines   []routine.RoutineJSON `json:"routines"`
+		Transition transition.Effect     `json:"transition"`
 	}{}
 	d.Routines = []*routine.Routine{}
 
 	if err := json.Unmarshal(data, &aux); err != nil {
-		return err
+		return describeJSONError(err)
 	}
+	if err := transition.Check(aux.Transition); err != nil {
+		return errors.New("field transition: " + err.Error())
+	}
+	d.Transition = aux.Transition
 
-	for _, v := range aux.Routines {
-		if rout, ok := routine.AllRoutines[v.Type]; !ok {
-			return errors.New("unrecognized routine type")
+	for i, v := range aux.Routines {
+		if newRout, ok := routine.NewRoutine(v.Type); !ok {
+			return errors.New("routine " + strconv.Itoa(i) + ": unrecognized routine type " + strconv.Quote(string(v.Type)))
 		} else {
-			var newRout routine.RoutineIface
-			newRout = reflect.New(reflect.ValueOf(rout).Elem().Type()).Interface().(routine.RoutineIface)
 			if err := json.Unmarshal(v.Routine, newRout); err != nil {
-				return err
+				return errors.New("routine " + strconv.Itoa(i) + " (" + string(v.Type) + "): " + describeJSONError(provider.ConfigFieldError(err)).Error())
 			}
 			if err := newRout.Check(); err != nil {
-				return err
+				return errors.New("routine " + strconv.Itoa(i) + " (" + string(v.Type) + "): " + err.Error())
 			}
 
 			d.Routines = append(d.Routines, &routine.Routine{
@@ -82,10 +87,17 @@ func (d *Dashboard) Init() error {
 	return nil
 }
 
-func (d *Dashboard) Update(now time.Time, values provider.ProviderValues) []DashboardMessage {
+// Update runs every routine on the dashboard. state is the physical state of the whole display, which routines that
+// implement routine.StatefulRoutine receive their own region of
+func (d *Dashboard) Update(now time.Time, values provider.ProviderValues, displaySize display.Size, state FlapState) []DashboardMessage {
 	msgs := make([]DashboardMessage, 0)
 	for _, rout := range d.Routines {
-		msg := rout.Routine.Update(now, values)
+		var msg *routine.Message
+		if stateful, ok := rout.Routine.(routine.StatefulRoutine); ok {
+			msg = stateful.UpdateWithState(now, values, regionState(displaySize, rout.Location, rout.Size, state))
+		} else {
+			msg = rout.Routine.Update(now, values)
+		}
 		if msg != nil {
 			dMsg := DashboardMessage{
 				rout.Location,
@@ -98,18 +110,38 @@ func (d *Dashboard) Update(now time.Time, values provider.ProviderValues) []Dash
 	return msgs
 }
 
+// regionState extracts the physical state of a routine's region. The region is only settled once every module in it
+// has reported that it stopped moving
+func regionState(displaySize display.Size, loc display.Location, size display.Size, state FlapState) routine.RegionState {
+	region := routine.RegionState{Text: relevantStateSubset(displaySize, loc, size, state.Text)}
+	if region.Text == "" || len(state.Moving) < displaySize.Width*displaySize.Height {
+		return region
+	}
+	region.Settled = true
+	for y := range size.Height {
+		start := ((loc.Y + y) * displaySize.Width) + loc.X
+		for _, moving := range state.Moving[start : start+size.Width] {
+			if moving {
+				region.Settled = false
+			}
+		}
+	}
+	return region
+}
+
 // relevantStateSubset extracts a selection of the state to send to a routine.
-// NOTE not currently used; might be used for something that computes the next state based on current state
 func relevantStateSubset(displaySize display.Size, loc display.Location, size display.Size, state string) string {
-	newState := ""
-	if len(state) < size.Width*size.Height {
-		return newState
+	// modules are counted in runes, as some flaps show characters that take more than a byte
+	modules := []rune(state)
+	if len(modules) < displaySize.Width*displaySize.Height {
+		return ""
 	}
+	var newState []rune
 	for y := range size.Height {
 		start := ((loc.Y + y) * displaySize.Width) + loc.X
 		end := start + size.Width
-		newState += state[start:end]
+		newState = append(newState, modules[start:end]...)
 	}
 
-	return newState
+	return string(newState)
 }
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"github.com/denverquane/go-splitflap/display"
 	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
 	"testing"
+	"time"
 )
 
 func TestDashboard_UnmarshalJSON(t *testing.T) {
@@ -24,7 +26,7 @@ func TestDashboard_UnmarshalJSON(t *testing.T) {
 		Routine: &routine.ClockRoutine{
 			RemoveLeadingZero: true,
 			Military:          true,
-			AMPMText:          true,
+			AMPMText:          false, // Check rejects AM/PM text on a 24 hour clock
 		},
 	},
 	}}
@@ -109,3 +111,91 @@ func TestDashboard_relevantStateSubset_multiline_routine(t *testing.T) {
 		t.Fatal("unexpected result for multiline routine string subset")
 	}
 }
+
+func TestDashboard_relevantStateSubset_multibyte(t *testing.T) {
+	displaySize := display.Size{Width: 4, Height: 2}
+	loc := display.Location{X: 1, Y: 0}
+	size := display.Size{Width: 2, Height: 2}
+
+	state := "°ABC" + "D°EF"
+	if newState := relevantStateSubset(displaySize, loc, size, state); newState != "AB°E" {
+		t.Fatal("state should be split by module, not by byte", newState)
+	}
+}
+
+func TestDashboard_Update_slowText(t *testing.T) {
+	alphabet := usb_serial.GlobalAlphabet
+	usb_serial.GlobalAlphabet = []rune(" ABC")
+	defer func() { usb_serial.GlobalAlphabet = alphabet }()
+
+	displaySize := display.Size{Width: 4, Height: 1}
+	d := Dashboard{Routines: []*routine.Routine{{
+		RoutineBase: routine.RoutineBase{
+			Type:     routine.SLOWTEXT,
+			Location: display.Location{X: 1, Y: 0},
+			Size:     display.Size{Width: 2, Height: 1},
+		},
+		Routine: &routine.SlowTextRoutine{Text: "CA", LetterDelayMs: 100},
+	}}}
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(now time.Time, state string) string {
+		msgs := d.Update(now, nil, displaySize, FlapState{Text: state, Moving: make([]bool, len(state))})
+		if len(msgs) == 0 {
+			return ""
+		}
+		return msgs[0].Text
+	}
+
+	start := time.Now()
+	if text := update(start, ""); text != "  " {
+		t.Fatal("slow text should blank its region first", text)
+	}
+	if text := update(start.Add(time.Millisecond*100), "ZCBZ"); text != "" {
+		t.Fatal("slow text should wait for its region to be blank", text)
+	}
+
+	// C is 3 flaps (195ms) from blank and A only 1 (65ms), so A starts 195ms + 100ms - 65ms = 230ms after C
+	start = start.Add(time.Millisecond * 200)
+	if text := update(start, "Z  Z"); text != "C " {
+		t.Fatal("first letter should start once the region is blank", text)
+	}
+	if text := update(start.Add(time.Millisecond*229), "ZA Z"); text != "C " {
+		t.Fatal("second letter started too early", text)
+	}
+	if text := update(start.Add(time.Millisecond*230), "ZB Z"); text != "CA" {
+		t.Fatal("second letter should start once its time has come", text)
+	}
+	if text := update(start.Add(time.Second), "ZCAZ"); text != "" {
+		t.Fatal("slow text should stop updating once revealed", text)
+	}
+}
+
+func TestDashboard_regionState(t *testing.T) {
+	displaySize := display.Size{Width: 3, Height: 2}
+	loc := display.Location{X: 1, Y: 0}
+	size := display.Size{Width: 2, Height: 2}
+
+	region := regionState(displaySize, loc, size, FlapState{
+		Text:   "ABC" + "DEF",
+		Moving: []bool{true, false, false, true, false, false},
+	})
+	if region.Text != "BCEF" || !region.Settled {
+		t.Fatal("modules moving outside of the region shouldn't unsettle it", region)
+	}
+
+	region = regionState(displaySize, loc, size, FlapState{
+		Text:   "ABC" + "DEF",
+		Moving: []bool{false, false, false, false, false, true},
+	})
+	if region.Settled {
+		t.Fatal("region with a moving module shouldn't be settled")
+	}
+
+	region = regionState(displaySize, loc, size, FlapState{})
+	if region.Text != "" || region.Settled {
+		t.Fatal("region shouldn't be settled before the display reports its state")
+	}
+}
@@ -3,34 +3,65 @@ package splitflap
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"log/slog"
-	"os"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/denverquane/go-splitflap/display"
 	"github.com/denverquane/go-splitflap/provider"
 	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/transition"
 )
 
 type Display struct {
 	Size         display.Size                  `json:"size"`
+	Port         string                        `json:"port,omitempty"` // serial port (or tcp://host:port) of this display's hardware
 	Translations map[rune]rune                 `json:"translations"`
-	Providers    map[string]*provider.Provider `json:"providers"`
+	Providers    map[string]*provider.Provider `json:"-"` // shared by every display of the hub
 	Dashboards   map[string]*Dashboard         `json:"dashboards"`
+	Playlists    map[string]*Playlist          `json:"playlists"`
 	Layout       []int                         `json:"layout"`
 	PollRate     int64                         `json:"poll_rate_ms"`
+	MotionPolicy MotionPolicy                  `json:"motion_policy"`
 
-	activeDashboard string
+	Schedules        map[string]*Schedule `json:"schedules"`
+	ScheduleTimezone string               `json:"schedule_timezone"`
 
-	state           string
-	stateSubscriber chan<- struct{}
-	filepath        string
-	inMessages      chan routine.Message
-	lockoutUntil    time.Time
+	activeDashboard string
+	activePlaylist  string
+
+	scheduleLoc           *time.Location
+	activeSchedule        string
+	scheduleOverrideUntil time.Time
+	beforeSchedule        *scheduleRestore // what the display showed before the schedule took over
+
+	state            string
+	moving           []bool // whether each module is still moving, as of the last state the hardware reported
+	stateSubscribers []chan<- struct{}
+	hub              *Hub
+	inMessages       chan directMessage
+	dashboardText    string            // the last text the active dashboard rendered, to show again once alerts are over
+	nextTransition   transition.Effect // the transition to use for the next text the dashboard renders
+	sent             string            // the last text sent to the hardware, in reading order, after translations
+	skippedUntil     time.Time         // if set, dashboardText was skipped by the motion policy, and is sent once this passes
+
+	// lock guards everything above. Size, Port and hub never change once the display is running, so they can be read
+	// without it
+	lock sync.RWMutex
+
+	alertLock  sync.Mutex
+	alerts     []*Alert
+	alertSeq   uint64
+	shownAlert string
 }
 
+// DefaultPollRateMs is how often a new display updates its dashboard
+const DefaultPollRateMs = 100
+
 func NewDisplay(size display.Size) *Display {
 	layout := make([]int, size.Height*size.Width)
 	for i := range layout {
@@ -41,116 +72,232 @@ func NewDisplay(size display.Size) *Display {
 		Translations: make(map[rune]rune),
 		Providers:    make(map[string]*provider.Provider),
 		Dashboards:   make(map[string]*Dashboard),
+		Playlists:    make(map[string]*Playlist),
 		Layout:       layout,
+		PollRate:     DefaultPollRateMs,
+		Schedules:    make(map[string]*Schedule),
 
 		activeDashboard: "",
+		activePlaylist:  "",
+		scheduleLoc:     time.UTC,
 		state:           "",
-		stateSubscriber: nil,
-		filepath:        "",
-		inMessages:      make(chan routine.Message),
+		inMessages:      make(chan directMessage),
 	}
 }
 
-func LoadDisplayFromFile(path string) (*Display, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	bytes, err := io.ReadAll(f)
-	if err != nil {
-		return nil, err
+// UnmarshalJSON reads the dashboards one at a time, so an invalid dashboard can be named
+func (d *Display) UnmarshalJSON(data []byte) error {
+	type plainDisplay Display // without this method, so it can be unmarshalled as usual
+	aux := struct {
+		*plainDisplay
+		Dashboards map[string]json.RawMessage `json:"dashboards"`
+	}{plainDisplay: (*plainDisplay)(d)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
 	}
 
-	var d Display
-	err = json.Unmarshal(bytes, &d)
-	if err != nil {
-		return nil, err
+	d.Dashboards = make(map[string]*Dashboard, len(aux.Dashboards))
+	for _, name := range slices.Sorted(maps.Keys(aux.Dashboards)) {
+		dashboard := &Dashboard{}
+		if err := json.Unmarshal(aux.Dashboards[name], dashboard); err != nil {
+			return errors.New("dashboard " + strconv.Quote(name) + ": " + err.Error())
+		}
+		d.Dashboards[name] = dashboard
 	}
+	return nil
+}
 
-	if err = validateLayout(d.Size, d.Layout); err != nil {
-		return nil, err
+// init validates a display loaded from JSON, and prepares it to be run
+func (d *Display) init() error {
+	if err := validateLayout(d.Size, d.Layout); err != nil {
+		return err
 	}
 	if d.PollRate < 100 {
-		return nil, errors.New("poll_rate_ms must be >= 100")
+		return errors.New("poll_rate_ms must be >= 100")
+	}
+	if d.Translations == nil {
+		d.Translations = make(map[rune]rune)
+	}
+	if d.Dashboards == nil {
+		d.Dashboards = make(map[string]*Dashboard)
+	}
+	if d.Playlists == nil {
+		d.Playlists = make(map[string]*Playlist)
+	}
+	for name, playlist := range d.Playlists {
+		if err := playlist.Check(d.Dashboards); err != nil {
+			return errors.New("invalid playlist " + name + ": " + err.Error())
+		}
+	}
+	if d.Schedules == nil {
+		d.Schedules = make(map[string]*Schedule)
+	}
+	for name, schedule := range d.Schedules {
+		if err := schedule.Check(d.Dashboards, d.Playlists); err != nil {
+			return errors.New("invalid schedule " + name + ": " + err.Error())
+		}
+	}
+	var err error
+	if d.scheduleLoc, err = time.LoadLocation(d.ScheduleTimezone); err != nil {
+		return err
 	}
-	d.filepath = path
 	d.activeDashboard = ""
-	d.inMessages = make(chan routine.Message)
-	return &d, nil
-}
-
-func WriteDisplayToFile(display *Display, path string) error {
-	display.filepath = path
-	return display.write()
+	d.activePlaylist = ""
+	d.inMessages = make(chan directMessage)
+	return nil
 }
 
 func (d *Display) ActiveDashboard() string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
 	return d.activeDashboard
 }
 
 func (d *Display) GetState() string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
 	return d.state
 }
 
-func (d *Display) GetFilepath() string {
-	return d.filepath
+// AddStateSubscriber registers a channel that is signalled whenever the display's state changes. Signals are dropped
+// while the channel is full, so give it a buffer of 1 to always hear about the latest change
+func (d *Display) AddStateSubscriber(s chan struct{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.stateSubscribers = append(d.stateSubscribers, s)
+}
+
+// GetDashboards returns a copy of every dashboard
+func (d *Display) GetDashboards() map[string]*Dashboard {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	dashboards := make(map[string]*Dashboard, len(d.Dashboards))
+	for name, dashboard := range d.Dashboards {
+		dashboards[name] = &Dashboard{
+			Routines:   append([]*routine.Routine{}, dashboard.Routines...),
+			Transition: dashboard.Transition,
+		}
+	}
+	return dashboards
+}
+
+// GetTranslations returns a copy of the display's character translations
+func (d *Display) GetTranslations() map[rune]rune {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	translations := make(map[rune]rune, len(d.Translations))
+	for src, dst := range d.Translations {
+		translations[src] = dst
+	}
+	return translations
 }
 
-func (d *Display) SetStateSubscriber(s chan struct{}) {
-	d.stateSubscriber = s
+// directMessage is text sent straight to the display, bypassing dashboards
+type directMessage struct {
+	text       string
+	transition transition.Effect
 }
 
 func (d *Display) Clear() {
-	d.Set(strings.Repeat(" ", d.Size.Height*d.Size.Width), 0)
+	d.Set(strings.Repeat(" ", d.Size.Height*d.Size.Width), transition.None)
 }
 
-func (d *Display) Set(str string, duration time.Duration) {
-	d.inMessages <- routine.Message{
-		Text:     str,
-		Duration: duration,
+// Set shows text on the display until a dashboard or alert replaces it. Use PushAlert to show text for a while
+func (d *Display) Set(str string, effect transition.Effect) {
+	d.inMessages <- directMessage{
+		text:       str,
+		transition: effect,
 	}
 }
 
-func (d *Display) write() error {
-	if d.filepath == "" {
-		return errors.New("filepath not set in Display struct")
+// modify applies a change to the display while holding its lock, then saves the hub the display belongs to (unless the
+// change fails). Nothing is changed while the hub's file has changes on disk that haven't been reloaded yet, as they
+// would be overwritten. The hub's writeLock is held throughout, so the file can't be reloaded between the check and the
+// save
+func (d *Display) modify(change func() error) error {
+	if d.hub == nil {
+		d.lock.Lock()
+		err := change()
+		d.lock.Unlock()
+		if err != nil {
+			return err
+		}
+		return errors.New("display does not belong to a hub, so it cannot be saved")
 	}
-	f, err := os.Create(d.filepath)
-	if err != nil {
+
+	d.hub.writeLock.Lock()
+	defer d.hub.writeLock.Unlock()
+	if err := d.hub.checkFile(); err != nil {
 		return err
 	}
-	defer f.Close()
-
-	bytes, err := json.MarshalIndent(d, "", "  ")
+	d.lock.Lock()
+	err := change()
+	d.lock.Unlock()
 	if err != nil {
 		return err
 	}
+	return d.hub.save()
+}
 
-	_, err = f.Write(bytes)
-	return err
+// SetTranslations replaces the character translations of the display, and saves them
+func (d *Display) SetTranslations(translations map[rune]rune) error {
+	return d.modify(func() error {
+		d.Translations = translations
+		return nil
+	})
 }
 
 func (d *Display) CreateDashboard(name string) error {
-	if _, ok := d.Dashboards[name]; ok {
-		return errors.New("dashboard already exists with that name")
-	}
-	d.Dashboards[name] = &Dashboard{Routines: []*routine.Routine{}}
-	return d.write()
+	return d.modify(func() error {
+		if _, ok := d.Dashboards[name]; ok {
+			return errors.New("dashboard already exists with that name")
+		}
+		d.Dashboards[name] = &Dashboard{Routines: []*routine.Routine{}}
+		return nil
+	})
 }
 
 func (d *Display) DeleteDashboard(name string) error {
-	if name == d.activeDashboard {
-		return errors.New("cannot delete currently active dashboard")
-	}
+	return d.modify(func() error {
+		if name == d.activeDashboard {
+			return errors.New("cannot delete currently active dashboard")
+		}
 
-	if _, ok := d.Dashboards[name]; !ok {
-		return errors.New("dashboard with that name doesn't exist")
-	}
+		if _, ok := d.Dashboards[name]; !ok {
+			return errors.New("dashboard with that name doesn't exist")
+		}
 
-	delete(d.Dashboards, name)
-	return d.write()
+		for playlistName, playlist := range d.Playlists {
+			if playlist.uses(name) {
+				return errors.New("cannot delete dashboard used by playlist " + playlistName)
+			}
+		}
+		for scheduleName, schedule := range d.Schedules {
+			if schedule.uses(name, "") {
+				return errors.New("cannot delete dashboard used by schedule " + scheduleName)
+			}
+		}
+
+		delete(d.Dashboards, name)
+		return nil
+	})
+}
+
+// SetDashboardTransition sets the transition used when the display changes over to a dashboard
+func (d *Display) SetDashboardTransition(dashboardName string, effect transition.Effect) error {
+	return d.modify(func() error {
+		dashboard, ok := d.Dashboards[dashboardName]
+		if !ok {
+			return errors.New("dashboard does not exist")
+		}
+		if err := transition.Check(effect); err != nil {
+			return err
+		}
+		dashboard.Transition = effect
+		return nil
+	})
 }
 
 // TODO prevent overlapping routines?
@@ -158,32 +305,52 @@ func (d *Display) AddRoutineToDashboard(dashboardName string, rout routine.Routi
 	loc := rout.Location
 	size := rout.Size
 
-	if dashboard, ok := d.Dashboards[dashboardName]; !ok {
-		return errors.New("dashboard does not exist")
-	} else if _, ok = routine.AllRoutines[rout.Type]; !ok {
-		return errors.New("routine type does not exist")
-	} else if loc.X < 0 || loc.Y < 0 || loc.X > d.Size.Width || loc.Y > d.Size.Height {
-		return errors.New("cannot add routine out of display bounds")
-	} else if loc.X+size.Width > d.Size.Width || loc.Y+size.Height > d.Size.Height {
-		return errors.New("adding routine with specified size would exceed display bounds")
-	} else {
-		err := dashboard.AddRoutine(rout)
-		if err != nil {
-			return err
+	return d.modify(func() error {
+		if dashboard, ok := d.Dashboards[dashboardName]; !ok {
+			return errors.New("dashboard does not exist")
+		} else if _, ok = routine.AllRoutines[rout.Type]; !ok {
+			return errors.New("routine type does not exist")
+		} else if loc.X < 0 || loc.Y < 0 || loc.X > d.Size.Width || loc.Y > d.Size.Height {
+			return errors.New("cannot add routine out of display bounds")
+		} else if loc.X+size.Width > d.Size.Width || loc.Y+size.Height > d.Size.Height {
+			return errors.New("adding routine with specified size would exceed display bounds")
+		} else {
+			return dashboard.AddRoutine(rout)
 		}
-		d.Dashboards[dashboardName] = dashboard
-		return d.write()
-	}
+	})
 }
 
+// DeactivateActiveDashboard deactivates the current dashboard, and stops any playlist that is driving it. This
+// overrides any schedule until the next schedule rule boundary
 func (d *Display) DeactivateActiveDashboard() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.overrideSchedule()
+	d.activePlaylist = ""
+	d.deactivateActiveDashboard()
+	d.notify()
+}
+
+// ActivateDashboard manually activates a dashboard, which stops any playlist that is currently running. This overrides
+// any schedule until the next schedule rule boundary
+func (d *Display) ActivateDashboard(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.overrideSchedule()
+	d.activePlaylist = ""
+	defer d.notify()
+	return d.activateDashboard(name)
+}
+
+func (d *Display) deactivateActiveDashboard() {
 	d.deactivateProvidersForDashboard(d.activeDashboard)
 	d.activeDashboard = ""
-	return
 }
 
-func (d *Display) ActivateDashboard(name string) error {
-	d.DeactivateActiveDashboard()
+func (d *Display) activateDashboard(name string) error {
+	d.deactivateActiveDashboard()
 	d.activateProvidersForDashboard(name)
 	if dashboard, ok := d.Dashboards[name]; !ok {
 		return errors.New("dashboard does not exist")
@@ -193,80 +360,202 @@ func (d *Display) ActivateDashboard(name string) error {
 			return err
 		}
 		d.activeDashboard = name
+		d.dashboardText = ""
+		d.skippedUntil = time.Time{}
+		d.nextTransition = dashboard.Transition
 	}
 	return nil
 }
 
-func (d *Display) Run(messages chan<- OutMessage, state <-chan string) {
+// FlapState is what the hardware reports: the character each module shows, and whether it is still moving
+type FlapState struct {
+	Text   string
+	Moving []bool
+}
+
+func (d *Display) Run(messages chan<- OutMessage, state <-chan FlapState) {
 
 	// TODO what if our display is already running when we change the layout?
+	d.lock.RLock()
 	invLayout := invertLayout(d.Layout)
+	pollRate := time.Millisecond * time.Duration(d.PollRate)
+	d.lock.RUnlock()
 
-	providerTicker := time.NewTicker(time.Millisecond * time.Duration(d.PollRate))
+	providerTicker := time.NewTicker(pollRate)
 
-	ticker := time.NewTicker(time.Millisecond * time.Duration(d.PollRate))
+	ticker := time.NewTicker(pollRate)
 
 	values := make(provider.ProviderValues)
 
-	// we use a single update loop to prevent races or needing locks, and communication with the splitflap is "serial" anyways
+	// we use a single update loop, and communication with the splitflap is "serial" anyways. The API changes the display
+	// from other goroutines though, so the loop holds the lock while it works, and releases it before sending anything on
 	for {
 		select {
 
 		// inMessages is the channel for actual final messages to be sent directly to the splitflap.
 		// So these can come from routines further down, but also manually overridden via API endpoints, for example
 		case msg := <-d.inMessages:
-			// if a message provides a minimum duration, then make sure no other routines interrupt it
-			if msg.Duration > 0 {
-				d.lockoutUntil = time.Now().Add(msg.Duration)
-			}
-			messages <- OutMessage{payload: arrangeToLayout(msg.Text, d.Layout)}
+			d.lock.Lock()
+			out := d.outMessage(display.RightPad(msg.text, d.Size), msg.transition, "message")
+			d.lock.Unlock()
+			messages <- out
 
 			// process state received from the Splitflap
 		case s := <-state:
-			s = arrangeToLayout(s, invLayout)
-			slog.Info("Received state from display", "state", s)
+			s.Text = arrangeToLayout(s.Text, invLayout)
+			slog.Info("Received state from display", "state", s.Text)
 
-			d.state = s
-			if d.stateSubscriber != nil {
-				d.stateSubscriber <- struct{}{}
-			}
+			d.lock.Lock()
+			d.state = s.Text
+			d.moving = arrangeSliceToLayout(s.Moving, invLayout)
+			d.notify()
+			d.lock.Unlock()
 
 		case <-providerTicker.C:
-			for name, p := range d.Providers {
-				values[name] = p.Provider.Values()
-			}
+			d.providerValues(values)
 
 			// run the update loop every tick
 		case now := <-ticker.C:
-
-			// TODO is this correct? Should a routine ever be updated if it doesn't belong to a dashboard?
-			if d.activeDashboard == "" {
-				break
-			}
-			// don't update if we have a minimum duration specified for the current state
-			if now.Before(d.lockoutUntil) {
-				break
+			d.lock.Lock()
+			out := d.tick(now, values)
+			d.lock.Unlock()
+			for _, msg := range out {
+				messages <- msg
 			}
+		}
+	}
+}
 
-			msgs := d.Dashboards[d.activeDashboard].Update(now, values)
-			if len(msgs) == 0 {
-				break
-			}
-			currentMessage := initMessage(d.Size)
-			for _, m := range msgs {
-				// TODO should also make sure the routine sends back *enough* text to fill its specified size?
-				if len([]rune(m.Text)) > m.Width*m.Height {
-					slog.Error("Routine Update() returned a message that is larger than the routine's specified size", "text", m.Text)
-				} else {
-					currentMessage = mergeMessageToCurrentText(d.Size, currentMessage, m.Location, m.Message)
-				}
-			}
+// tick runs schedules, playlists, alerts and the active dashboard, and returns what should be sent to the hardware.
+// Must be called with the lock held
+func (d *Display) tick(now time.Time, values provider.ProviderValues) []OutMessage {
+	var out []OutMessage
 
-			messages <- OutMessage{
-				payload: arrangeToLayout(string(applyTranslations(currentMessage, d.Translations)), d.Layout),
+	blank := d.updateSchedule(now)
+	if d.activePlaylist != "" {
+		d.updatePlaylist(now)
+	}
+	if blank || d.activeDashboard == "" {
+		d.dashboardText = ""
+	}
+
+	// alerts cover the dashboard; once the last one is over, show what the dashboard was showing again
+	alert, alertChanged, alertShowing := d.updateAlerts(now)
+	if alertChanged {
+		d.notify()
+	}
+	if alertChanged && alertShowing {
+		out = append(out, d.outMessage(display.RightPad(alert.Text, d.Size), alert.Transition, "alert:"+alert.Source))
+	} else if alertChanged || (blank && !alertShowing) {
+		text := d.dashboardText
+		if text == "" {
+			text = string(initMessage(d.Size))
+		}
+		out = append(out, d.outMessage(text, transition.None, d.dashboardSource()))
+	}
+
+	// TODO is this correct? Should a routine ever be updated if it doesn't belong to a dashboard?
+	if d.activeDashboard == "" {
+		return out
+	}
+
+	msgs := d.Dashboards[d.activeDashboard].Update(now, values, d.Size, FlapState{Text: d.state, Moving: d.moving})
+	if len(msgs) == 0 {
+		// a skipped frame that wasn't replaced in time is shown after all
+		if !d.skippedUntil.IsZero() && !now.Before(d.skippedUntil) && !alertShowing {
+			d.skippedUntil = time.Time{}
+			out = append(out, d.outMessage(d.dashboardText, transition.None, d.dashboardSource()))
+		}
+		return out
+	}
+	// routines that didn't update keep showing what they rendered last
+	currentMessage := initMessage(d.Size)
+	if d.dashboardText != "" {
+		currentMessage = []rune(d.dashboardText)
+	}
+	for _, m := range msgs {
+		// TODO should also make sure the routine sends back *enough* text to fill its specified size?
+		if len([]rune(m.Text)) > m.Width*m.Height {
+			slog.Error("Routine Update() returned a message that is larger than the routine's specified size", "text", m.Text)
+		} else {
+			currentMessage = mergeMessageToCurrentText(d.Size, currentMessage, m.Location, m.Message)
+		}
+	}
+
+	// the dashboard keeps running underneath an alert, so it's up to date when the alert is over
+	d.dashboardText = string(currentMessage)
+	if alertShowing {
+		return out
+	}
+	// text that would be replaced before the flaps could settle on it isn't worth the wear. Text is only held back for
+	// as long as the first skipped frame would have been shown though, so text that always changes faster than the
+	// flaps settle, like a timer, still advances
+	overdue := !d.skippedUntil.IsZero() && !now.Before(d.skippedUntil)
+	if d.MotionPolicy.SkipSlowFrames && d.nextTransition == transition.None && !overdue {
+		if shown := displayTime(msgs); shown > 0 && d.estimateMotion(d.dashboardText).SettleTime() > shown {
+			if d.skippedUntil.IsZero() {
+				d.skippedUntil = now.Add(shown)
 			}
+			return out
 		}
 	}
+	d.skippedUntil = time.Time{}
+	out = append(out, d.outMessage(d.dashboardText, d.nextTransition, d.dashboardSource()))
+	d.nextTransition = transition.None
+	return out
+}
+
+// notify tells the state subscribers that something they show changed. It never blocks: a subscriber may be waiting
+// for the lock, and it only needs to know that there is something new to fetch. Must be called with the lock held
+func (d *Display) notify() {
+	for _, subscriber := range d.stateSubscribers {
+		select {
+		case subscriber <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// providerValues fetches the latest values of every provider
+func (d *Display) providerValues(values provider.ProviderValues) {
+	if d.hub != nil {
+		d.hub.lock.Lock()
+		defer d.hub.lock.Unlock()
+	}
+	clear(values) // drop the values of providers that were deleted
+	for name, p := range d.Providers {
+		values[name] = p.Provider.Values()
+	}
+}
+
+// outMessage prepares text covering the whole display to be sent to the hardware, applying the display's translations
+// and layout, and breaking it into the frames of a transition. source labels the wear the text causes
+func (d *Display) outMessage(text string, effect transition.Effect, source string) OutMessage {
+	text = string(applyTranslations([]rune(text), d.Translations))
+	d.sent = text
+	msg := OutMessage{payload: arrangeToLayout(text, d.Layout), hold: d.MotionPolicy.HoldUnchanged, source: source}
+	if effect == transition.None {
+		return msg
+	}
+
+	frames, err := transition.Frames(effect, d.Size, d.state, text)
+	if err != nil {
+		slog.Error("Failed to create transition, changing text without it", "transition", effect, "error", err.Error())
+		return msg
+	}
+	for i := range frames {
+		frames[i].Text = arrangeToLayout(frames[i].Text, d.Layout)
+	}
+	msg.frames = frames
+	return msg
+}
+
+// dashboardSource labels the wear caused by what the active dashboard shows
+func (d *Display) dashboardSource() string {
+	if d.activeDashboard == "" {
+		return "blank"
+	}
+	return "dashboard:" + d.activeDashboard
 }
 
 func initMessage(size display.Size) []rune {
@@ -317,10 +606,28 @@ func invertLayout(layout []int) []int {
 	return final
 }
 
+// arrangeToLayout moves each character of current to where the layout puts it. Characters are runes, not bytes, as
+// some flaps and usb_serial.HoldCharacter take more than one byte. Characters missing from current are left blank
 func arrangeToLayout(current string, layout []int) string {
-	final := ""
-	for _, v := range layout {
-		final += string(current[v])
+	runes := []rune(current)
+	final := make([]rune, len(layout))
+	for i, v := range layout {
+		final[i] = ' '
+		if v < len(runes) {
+			final[i] = runes[v]
+		}
+	}
+	return string(final)
+}
+
+// arrangeSliceToLayout is arrangeToLayout for per-module values other than text. Values missing from current are left
+// as their zero value
+func arrangeSliceToLayout[T any](current []T, layout []int) []T {
+	final := make([]T, len(layout))
+	for i, v := range layout {
+		if v < len(current) {
+			final[i] = current[v]
+		}
 	}
 	return final
 }
@@ -337,18 +644,26 @@ func (d *Display) swapProviderPollrate(dashboard string, active bool) {
 	if dash, ok := d.Dashboards[dashboard]; ok {
 		for _, rout := range dash.Routines {
 			providerName := rout.Routine.GetProviderName()
-			if providerName != "" {
-				if prov, ok := d.Providers[providerName]; ok {
-					var pollrate int
-					if active {
-						pollrate = prov.ActivePollRateSecs
-					} else {
-						pollrate = prov.BackgroundPollRateSecs
-					}
-					prov.Provider.SetPollRateSecs(pollrate)
-					slog.Info("provider pollrate changed because of a change to a dependant routine", "provider", providerName, "active", active, "pollrate", pollrate)
-				}
+			if providerName == "" {
+				continue
+			}
+			// providers are shared, so the hub keeps track of which displays are using each of them
+			if d.hub != nil {
+				d.hub.useProvider(d, providerName, active)
+			} else if prov, ok := d.Providers[providerName]; ok {
+				setProviderPollRate(providerName, prov, active)
 			}
 		}
 	}
 }
+
+func setProviderPollRate(name string, prov *provider.Provider, active bool) {
+	var pollrate int
+	if active {
+		pollrate = prov.ActivePollRateSecs
+	} else {
+		pollrate = prov.BackgroundPollRateSecs
+	}
+	prov.Provider.SetPollRateSecs(pollrate)
+	slog.Info("provider pollrate changed because of a change to a dependant routine", "provider", name, "active", active, "pollrate", pollrate)
+}
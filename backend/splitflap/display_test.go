@@ -2,9 +2,17 @@ package splitflap
 
 import (
 	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/provider"
 	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+	"github.com/denverquane/go-splitflap/transition"
 	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDisplay_mergeMessageToCurrentText_simple(t *testing.T) {
@@ -87,6 +95,28 @@ func TestDisplay_arrangeToLayout(t *testing.T) {
 	}
 }
 
+func TestDisplay_outMessage_transition(t *testing.T) {
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	d.Layout = []int{3, 2, 1, 0}
+
+	// the wipe reveals the text left to right, holding the modules it hasn't reached yet. The hold character is more
+	// than one byte, so it only reaches the modules intact if the layout moves whole characters
+	msg := d.outMessage("ABCD", transition.Wipe, "test")
+	hold := string(rune(usb_serial.HoldCharacter))
+	expected := []string{"___A", "__BA", "_CBA", "DCBA"}
+	if len(msg.frames) != len(expected) {
+		t.Fatal("expected", len(expected), "frames, got", len(msg.frames))
+	}
+	for i, frame := range msg.frames {
+		if want := strings.ReplaceAll(expected[i], "_", hold); frame.Text != want {
+			t.Errorf("frame %d: expected %q, got %q", i, want, frame.Text)
+		}
+	}
+	if msg.payload != "DCBA" {
+		t.Fatal("payload should be arranged to the layout", msg.payload)
+	}
+}
+
 func TestDisplay_mergeMessageAndArrange(t *testing.T) {
 	size := display.Size{
 		Width:  12,
@@ -137,3 +167,142 @@ func TestDisplay_StateLayout(t *testing.T) {
 		t.Fatal("arrange state to layout failed")
 	}
 }
+
+func TestDisplay_concurrentAccess(t *testing.T) {
+	hub := NewHub()
+	hub.filepath = filepath.Join(t.TempDir(), "display.json")
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	d.PollRate = 5
+	if err := hub.AddDisplay(DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan OutMessage)
+	state := make(chan FlapState)
+	go d.Run(messages, state)
+	go func() {
+		for range messages {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := "dash" + strconv.Itoa(i)
+			for j := 0; j < 50; j++ {
+				if err := d.CreateDashboard(name); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := d.AddRoutineToDashboard(name, routine.Routine{
+					RoutineBase: routine.RoutineBase{Type: routine.TEXT, Size: display.Size{Width: 4, Height: 1}},
+					Routine:     &routine.TextRoutine{Text: "HI"},
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := d.ActivateDashboard(name); err != nil {
+					t.Error(err)
+					return
+				}
+				d.Set("ABCD", transition.None)
+				state <- FlapState{Text: "ABCD", Moving: make([]bool, 4)}
+				d.GetDashboards()
+				d.GetState()
+				d.ActiveDashboard()
+				d.DeactivateActiveDashboard()
+				if err := d.DeleteDashboard(name); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestDisplay_Run_multibyteState(t *testing.T) {
+	hub := NewHub()
+	hub.filepath = filepath.Join(t.TempDir(), "display.json")
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	d.PollRate = 5
+	if err := hub.AddDisplay(DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddRoutineToDashboard("main", routine.Routine{
+		RoutineBase: routine.RoutineBase{
+			Type:     routine.SLOWTEXT,
+			Location: display.Location{X: 1, Y: 0},
+			Size:     display.Size{Width: 2, Height: 1},
+		},
+		Routine: &routine.SlowTextRoutine{Text: "AB"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ActivateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan OutMessage)
+	state := make(chan FlapState)
+	go d.Run(messages, state)
+	next := func() string {
+		select {
+		case msg := <-messages:
+			return msg.payload
+		case <-time.After(time.Second * 2):
+			t.Fatal("timed out waiting for the display to send text")
+			return ""
+		}
+	}
+	if text := next(); text != "    " {
+		t.Fatal("slow text should blank its region first", text)
+	}
+
+	// the modules outside the region show a flap that takes more than one byte. The region in between is blank, which
+	// the slow text only notices if the state is split by module
+	state <- FlapState{Text: "°  °", Moving: make([]bool, 4)}
+	if text := next(); text != " AB " {
+		t.Fatal("slow text should be revealed once its region is blank", text)
+	}
+	if s := d.GetState(); s != "°  °" {
+		t.Fatal("state should reach the display intact", s)
+	}
+}
+
+func TestDisplay_tick_multibyteValue(t *testing.T) {
+	hub := NewHub()
+	hub.filepath = filepath.Join(t.TempDir(), "display.json")
+	d := NewDisplay(display.Size{Width: 6, Height: 1})
+	d.Layout = []int{5, 4, 3, 2, 1, 0}
+	if err := hub.AddDisplay(DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+	decimals := 0
+	if err := d.AddRoutineToDashboard("main", routine.Routine{
+		RoutineBase: routine.RoutineBase{Type: routine.VALUE, Size: display.Size{Width: 6, Height: 1}},
+		Routine:     &routine.ValueRoutine{ProviderName: "p", ProviderValue: "v", Format: "{value}°{units}", Decimals: &decimals},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ActivateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := d.tick(time.Now(), provider.ProviderValues{"p": {"v": 21.2, "units": "C"}})
+	if len(out) != 1 {
+		t.Fatal("expected the value to be sent, got", out)
+	}
+	// ° is one module, so the text is padded and reversed by module rather than by byte
+	if out[0].payload != "C°12  " {
+		t.Fatal("expected \"C°12  \", got", strconv.Quote(out[0].payload))
+	}
+}
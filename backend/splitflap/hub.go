@@ -0,0 +1,349 @@
+package splitflap
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/denverquane/go-splitflap/provider"
+)
+
+// DefaultDisplayID is the ID given to the display of a config file from before multiple displays were supported
+const DefaultDisplayID = "default"
+
+// Hub is everything one backend process manages: any number of named displays, each with its own hardware, and the
+// providers they all share (so that e.g. the weather is only fetched once)
+type Hub struct {
+	SchemaVersion int                           `json:"schema_version"`
+	Providers     map[string]*provider.Provider `json:"providers"`
+	Displays      map[string]*Display           `json:"displays"`
+
+	filepath      string
+	fileHash      [sha256.Size]byte            // of the file as it was last loaded or saved, to notice when something else changes it
+	providerUsers map[string]map[*Display]bool // the displays with an active dashboard that uses each provider
+	running       map[string]bool              // whether each provider has been started (and not stopped since)
+	invalid       map[string]error             // the providers whose config is invalid (e.g. saved by an older version), and why. They aren't started
+
+	// lock guards the maps above. Lock a display before the hub, never the other way round
+	lock sync.Mutex
+	// writeLock guards filepath and fileHash, and is held while the file is saved or reloaded, and while providers are
+	// started, so that starting one (which can block) doesn't need lock. It is taken before any display lock
+	writeLock sync.Mutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		SchemaVersion: SchemaVersion,
+		Providers:     make(map[string]*provider.Provider),
+		Displays:      make(map[string]*Display),
+		providerUsers: make(map[string]map[*Display]bool),
+		running:       make(map[string]bool),
+		invalid:       make(map[string]error),
+	}
+}
+
+func validDisplayID(id string) error {
+	if id == "" {
+		return errors.New("display id cannot be empty")
+	}
+	if strings.ContainsAny(id, "/{}") {
+		return errors.New("display id cannot contain '/', '{' or '}'")
+	}
+	return nil
+}
+
+// AddDisplay adds a display to the hub. The display uses the hub's providers from now on
+func (h *Hub) AddDisplay(id string, d *Display) error {
+	if err := validDisplayID(id); err != nil {
+		return err
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.Displays[id]; ok {
+		return errors.New("display already exists with that id")
+	}
+	d.Providers = h.Providers
+	d.hub = h
+	h.Displays[id] = d
+	return nil
+}
+
+func (h *Hub) Display(id string) (*Display, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	d, ok := h.Displays[id]
+	return d, ok
+}
+
+// DisplayIDs returns the IDs of every display, sorted
+func (h *Hub) DisplayIDs() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	ids := make([]string, 0, len(h.Displays))
+	for id := range h.Displays {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// DefaultDisplay returns the ID of the display that is used when a client doesn't ask for a specific one: the display
+// named DefaultDisplayID if there is one, otherwise the first by ID
+func (h *Hub) DefaultDisplay() string {
+	if _, ok := h.Display(DefaultDisplayID); ok {
+		return DefaultDisplayID
+	}
+	if ids := h.DisplayIDs(); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+func LoadHubFromFile(path string) (*Hub, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bytes, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	h, version, err := parseHubFile(bytes)
+	if err != nil {
+		return nil, err
+	}
+	h.filepath = path
+	h.fileHash = sha256.Sum256(bytes)
+	if version < SchemaVersion {
+		h.writeLock.Lock()
+		defer h.writeLock.Unlock()
+		// the upgraded config works just as well from memory, and is saved with the next change
+		if err = h.upgradeFile(bytes, version); err != nil {
+			slog.Warn("Failed to save the upgraded config file, running from the upgraded config anyway", "file", path, "error", err.Error())
+		}
+	}
+	return h, nil
+}
+
+// parseHubFile reads a config file of any schema version, returning the version it was
+func parseHubFile(bytes []byte) (*Hub, int, error) {
+	migrated, version, err := migrateConfig(bytes)
+	if err != nil {
+		return nil, 0, err
+	}
+	h, err := parseHub(migrated)
+	return h, version, err
+}
+
+func parseHub(bytes []byte) (*Hub, error) {
+	aux := struct {
+		Providers map[string]json.RawMessage `json:"providers"`
+		Displays  map[string]json.RawMessage `json:"displays"`
+	}{}
+	if err := json.Unmarshal(bytes, &aux); err != nil {
+		return nil, describeJSONError(err)
+	}
+	if len(aux.Displays) == 0 {
+		return nil, errors.New("at least one display must be configured")
+	}
+
+	// go through everything in order, so the same file always fails with the same error
+	h := NewHub()
+	for _, name := range slices.Sorted(maps.Keys(aux.Providers)) {
+		prov := &provider.Provider{}
+		if err := json.Unmarshal(aux.Providers[name], prov); err != nil {
+			return nil, errors.New("provider " + strconv.Quote(name) + ": " + describeJSONError(err).Error())
+		}
+		// a config that was valid before the checks got stricter shouldn't stop everything else from running, so the
+		// provider is only disabled until it is fixed
+		if err := prov.Check(); err != nil {
+			slog.Warn("Provider config is invalid, not starting it until it is fixed", "provider", name, "error", err.Error())
+			h.invalid[name] = err
+		}
+		h.Providers[name] = prov
+	}
+	for _, id := range slices.Sorted(maps.Keys(aux.Displays)) {
+		d := &Display{}
+		err := json.Unmarshal(aux.Displays[id], d)
+		if err == nil {
+			err = d.init()
+		}
+		if err == nil {
+			err = h.AddDisplay(id, d)
+		}
+		if err != nil {
+			return nil, errors.New("display " + strconv.Quote(id) + ": " + describeJSONError(err).Error())
+		}
+	}
+	return h, nil
+}
+
+// upgradeFile keeps the original of a config file that was upgraded from an older schema version as a backup, e.g.
+// display.json.v1.bak, then saves the upgraded hub over it. Must be called with writeLock held
+func (h *Hub) upgradeFile(original []byte, version int) error {
+	backup := h.filepath + ".v" + strconv.Itoa(version) + ".bak"
+	if err := writeFileAtomic(backup, original); err != nil {
+		return errors.New("failed to back up config file before upgrading it: " + err.Error())
+	}
+	slog.Info("Upgraded config file to the current schema version", "file", h.filepath, "from", version,
+		"to", SchemaVersion, "backup", backup)
+	return h.save()
+}
+
+// WriteHubToFile saves the hub to path, replacing whatever is there, and keeps it there from now on
+func WriteHubToFile(hub *Hub, path string) error {
+	hub.writeLock.Lock()
+	hub.filepath = path
+	hub.fileHash, _ = hashFile(path)
+	hub.writeLock.Unlock()
+	return hub.write()
+}
+
+// StartProviders starts every provider with a valid config, polling at its background rate until a display uses it
+func (h *Hub) StartProviders() error {
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+
+	h.lock.Lock()
+	stopped := make(map[string]*provider.Provider)
+	for name, prov := range h.Providers {
+		if !h.running[name] && h.invalid[name] == nil {
+			stopped[name] = prov
+		}
+	}
+	h.lock.Unlock()
+
+	for _, name := range slices.Sorted(maps.Keys(stopped)) {
+		if err := h.startProvider(name, stopped[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startProvider(name string, prov *provider.Provider) error {
+	prov.Provider.SetPollRateSecs(prov.BackgroundPollRateSecs)
+	if err := prov.Provider.Start(); err != nil {
+		return errors.New("failed to start provider " + name + ": " + err.Error())
+	}
+	return nil
+}
+
+// write saves the hub to its file. Every display is locked while the hub is marshalled, so the file is a consistent
+// snapshot even while the displays are being changed. The file is replaced in one go, so a crash while saving can't
+// truncate it, and it isn't replaced at all if it was changed on disk since it was last loaded or saved
+func (h *Hub) write() error {
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+	return h.save()
+}
+
+// save is write, with writeLock held
+func (h *Hub) save() error {
+	if err := h.checkFile(); err != nil {
+		return err
+	}
+
+	// lock in the same order every time
+	for _, id := range h.DisplayIDs() {
+		d, _ := h.Display(id)
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+	}
+	h.lock.Lock()
+	bytes, err := json.MarshalIndent(h, "", "  ")
+	h.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = writeFileAtomic(h.filepath, bytes); err != nil {
+		return err
+	}
+	h.fileHash = sha256.Sum256(bytes)
+	return nil
+}
+
+// checkFile returns ErrConfigChanged if the file was changed by something else since the hub last loaded or saved it.
+// Must be called with writeLock held
+func (h *Hub) checkFile() error {
+	if h.filepath == "" {
+		return errors.New("filepath not set in Hub struct")
+	}
+	hash, err := hashFile(h.filepath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if hash != h.fileHash {
+		return ErrConfigChanged
+	}
+	return nil
+}
+
+func hashFile(path string) ([sha256.Size]byte, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(bytes), nil
+}
+
+// writeFileAtomic writes a file next to path, then renames it over path, so that path either has its old contents or
+// the new ones
+func writeFileAtomic(path string, bytes []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// useProvider records whether a display has an active dashboard that uses a provider. The provider polls at its
+// active rate while any display uses it, and falls back to its background rate once none do. Users are recorded even
+// if the provider doesn't exist yet, so it starts at the right rate once it is created
+func (h *Hub) useProvider(d *Display, providerName string, using bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	users, ok := h.providerUsers[providerName]
+	if !ok {
+		users = make(map[*Display]bool)
+		h.providerUsers[providerName] = users
+	}
+	if using {
+		users[d] = true
+	} else {
+		delete(users, d)
+		if len(users) > 0 {
+			return
+		}
+	}
+	if prov, ok := h.Providers[providerName]; ok {
+		setProviderPollRate(providerName, prov, using)
+	}
+}
@@ -0,0 +1,137 @@
+package splitflap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+const legacyDisplayJSON = `{
+  "size": {"width": 4, "height": 1},
+  "translations": {},
+  "providers": {
+    "weather": {"type": "WEATHER_CURRENT", "active_poll_rate_secs": 60, "background_poll_rate_secs": 600, "config": {"location_id": 1, "units": "C"}}
+  },
+  "dashboards": {"main": {"routines": []}},
+  "layout": [0, 1, 2, 3],
+  "poll_rate_ms": 100
+}`
+
+func writeTestFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "display.json")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHubFromFile_legacyDisplay(t *testing.T) {
+	path := writeTestFile(t, legacyDisplayJSON)
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := hub.Display(DefaultDisplayID)
+	if !ok || len(hub.Displays) != 1 {
+		t.Fatal("a legacy config should become the hub's only display")
+	}
+	if _, ok = hub.Providers["weather"]; !ok {
+		t.Fatal("the legacy display's providers should belong to the hub")
+	}
+	if _, ok = d.Providers["weather"]; !ok {
+		t.Fatal("the display should use the hub's providers")
+	}
+
+	// saving writes the multi-display format, which must load back the same
+	if err = d.CreateDashboard("other"); err != nil {
+		t.Fatal(err)
+	}
+	hub, err = LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ = hub.Display(DefaultDisplayID)
+	if _, ok = d.Dashboards["other"]; !ok || len(hub.Providers) != 1 {
+		t.Fatal("hub did not survive a round trip through its file")
+	}
+}
+
+func TestLoadHubFromFile_multipleDisplays(t *testing.T) {
+	path := writeTestFile(t, `{
+  "providers": {},
+  "displays": {
+    "lobby": {"size": {"width": 2, "height": 1}, "port": "/dev/ttyACM0", "layout": [0, 1], "poll_rate_ms": 100},
+    "kitchen": {"size": {"width": 3, "height": 1}, "port": "tcp://kitchen:7070", "layout": [0, 1, 2], "poll_rate_ms": 100}
+  }
+}`)
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := hub.DisplayIDs()
+	if len(ids) != 2 || ids[0] != "kitchen" || ids[1] != "lobby" {
+		t.Fatal("expected both displays, sorted by id", ids)
+	}
+	if hub.DefaultDisplay() != "kitchen" {
+		t.Fatal("without a display named default, the first display should be the default")
+	}
+	lobby, _ := hub.Display("lobby")
+	if lobby.Port != "/dev/ttyACM0" || lobby.Size.Width != 2 {
+		t.Fatal("display config was not loaded")
+	}
+
+	path = writeTestFile(t, `{"providers": {}, "displays": {"lobby": {"size": {"width": 2, "height": 1}, "layout": [0], "poll_rate_ms": 100}}}`)
+	if _, err = LoadHubFromFile(path); err == nil {
+		t.Fatal("an invalid display should fail to load")
+	}
+}
+
+type pollRateProvider struct {
+	pollRateSecs int
+}
+
+func (p *pollRateProvider) Check() error                     { return nil }
+func (p *pollRateProvider) Start() error                     { return nil }
+func (p *pollRateProvider) SetPollRateSecs(rate int)         { p.pollRateSecs = rate }
+func (p *pollRateProvider) Stop()                            {}
+func (p *pollRateProvider) Values() provider.PValues         { return nil }
+func (p *pollRateProvider) Parameters() []provider.Parameter { return nil }
+
+func TestHub_sharedProviderPollRate(t *testing.T) {
+	weather := &pollRateProvider{}
+	hub := NewHub()
+	hub.filepath = filepath.Join(t.TempDir(), "display.json")
+	hub.Providers["weather"] = &provider.Provider{ActivePollRateSecs: 60, BackgroundPollRateSecs: 600, Provider: weather}
+
+	for _, id := range []string{"lobby", "kitchen"} {
+		d := NewDisplay(display.Size{Width: 4, Height: 1})
+		d.Dashboards["weather"] = &Dashboard{Routines: []*routine.Routine{{
+			RoutineBase: routine.RoutineBase{Type: routine.TEMPERATURE, Size: display.Size{Width: 4, Height: 1}},
+			Routine:     &routine.TemperatureRoutine{ProviderName: "weather"},
+		}}}
+		if err := hub.AddDisplay(id, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lobby, _ := hub.Display("lobby")
+	kitchen, _ := hub.Display("kitchen")
+
+	if err := lobby.ActivateDashboard("weather"); err != nil {
+		t.Fatal(err)
+	}
+	if err := kitchen.ActivateDashboard("weather"); err != nil {
+		t.Fatal(err)
+	}
+	kitchen.DeactivateActiveDashboard()
+	if weather.pollRateSecs != 60 {
+		t.Fatal("provider should keep its active poll rate while another display uses it")
+	}
+	lobby.DeactivateActiveDashboard()
+	if weather.pollRateSecs != 600 {
+		t.Fatal("provider should fall back to its background poll rate once no display uses it")
+	}
+}
@@ -0,0 +1,62 @@
+package splitflap
+
+import (
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/transition"
+)
+
+// MotionPolicy trades exactly what the display shows for less flap travel
+type MotionPolicy struct {
+	// SkipSlowFrames doesn't send dashboard text that would be replaced before the flaps could settle on it. If it isn't
+	// replaced after all, it is sent late, and text that keeps being replaced is still sent every so often
+	SkipSlowFrames bool `json:"skip_slow_frames"`
+	// HoldUnchanged sends modules whose character doesn't change as usb_serial.HoldCharacter, instead of resending it
+	HoldUnchanged bool `json:"hold_unchanged"`
+}
+
+func (d *Display) GetMotionPolicy() MotionPolicy {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.MotionPolicy
+}
+
+// SetMotionPolicy replaces the motion policy of the display, and saves it
+func (d *Display) SetMotionPolicy(policy MotionPolicy) error {
+	return d.modify(func() error {
+		d.MotionPolicy = policy
+		return nil
+	})
+}
+
+// EstimateMotion estimates the flap travel of changing the display from what it currently shows to text. text is in
+// reading order, before translations
+func (d *Display) EstimateMotion(text string) transition.Motion {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.estimateMotion(text)
+}
+
+// estimateMotion is EstimateMotion for callers that hold the lock
+func (d *Display) estimateMotion(text string) transition.Motion {
+	text = string(applyTranslations([]rune(display.RightPad(text, d.Size)), d.Translations))
+	from := d.state
+	if len([]rune(from)) != d.Size.Width*d.Size.Height {
+		// the hardware hasn't reported its state, so assume it shows what was last sent
+		from = d.sent
+	}
+	return transition.EstimateMotion(from, text)
+}
+
+// displayTime is how long the text made up of msgs is going to be shown for: the shortest duration any of the routines
+// expect to show their text for, or 0 if none of them know
+func displayTime(msgs []DashboardMessage) time.Duration {
+	shortest := time.Duration(0)
+	for _, m := range msgs {
+		if m.Duration > 0 && (shortest == 0 || m.Duration < shortest) {
+			shortest = m.Duration
+		}
+	}
+	return shortest
+}
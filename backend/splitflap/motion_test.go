@@ -0,0 +1,84 @@
+package splitflap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/routine"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+)
+
+func TestDisplay_EstimateMotion(t *testing.T) {
+	alphabet := usb_serial.GlobalAlphabet
+	usb_serial.GlobalAlphabet = []rune(" ABC")
+	defer func() { usb_serial.GlobalAlphabet = alphabet }()
+
+	d := NewDisplay(display.Size{Width: 3, Height: 1})
+	d.Translations['X'] = 'C'
+
+	// without state from the hardware, the estimate starts from what was last sent
+	d.outMessage("AAA", "", "test")
+	m := d.EstimateMotion("X")
+	if m.Total != 2+3+3 || m.Changed != 3 {
+		t.Fatal("estimate should start from the last text sent, and apply translations", m)
+	}
+
+	d.state = "CAA"
+	if m = d.EstimateMotion("C"); m.Total != 6 || m.Changed != 2 {
+		t.Fatal("estimate should start from the state the hardware reported", m)
+	}
+}
+
+func TestDisplayTime(t *testing.T) {
+	msgs := []DashboardMessage{
+		{Message: routine.Message{Text: "A"}},
+		{Message: routine.Message{Text: "B", Duration: time.Second * 2}},
+		{Message: routine.Message{Text: "C", Duration: time.Second}},
+	}
+	if displayTime(msgs) != time.Second {
+		t.Fatal("text is only shown for as long as its shortest lived routine message")
+	}
+	if displayTime(msgs[:1]) != 0 {
+		t.Fatal("display time should be unknown if no routine knows it")
+	}
+}
+
+func TestHoldUnchanged(t *testing.T) {
+	hold := string(rune(usb_serial.HoldCharacter))
+	if text := holdUnchanged("ABC", "ABD"); text != hold+hold+"D" {
+		t.Fatal("unchanged modules should be held", text)
+	}
+	if text := holdUnchanged("", "ABD"); text != "ABD" {
+		t.Fatal("nothing should be held without a previous text", text)
+	}
+}
+
+func TestDisplay_skipSlowFrames(t *testing.T) {
+	alphabet := usb_serial.GlobalAlphabet
+	// the digits are far enough from blank that no frame of a timer can settle within its second
+	usb_serial.GlobalAlphabet = []rune(" ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789:")
+	defer func() { usb_serial.GlobalAlphabet = alphabet }()
+
+	d := NewDisplay(display.Size{Width: 5, Height: 1})
+	d.MotionPolicy.SkipSlowFrames = true
+	d.Dashboards["timer"] = &Dashboard{Routines: []*routine.Routine{{
+		RoutineBase: routine.RoutineBase{Type: routine.TIMER, Size: display.Size{Width: 5, Height: 1}},
+		Routine:     &routine.TimerRoutine{End: time.Now().Add(time.Hour)},
+	}}}
+	if err := d.activateDashboard("timer"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	sent := 0
+	for i := 0; i < 4; i++ {
+		sent += len(d.tick(now.Add(time.Duration(i)*time.Second), nil))
+	}
+	if sent == 0 {
+		t.Fatal("a timer should still advance when every frame is too slow to settle")
+	}
+	if sent == 4 {
+		t.Fatal("frames that can't settle should be skipped")
+	}
+}
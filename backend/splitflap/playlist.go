@@ -0,0 +1,217 @@
+package splitflap
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/denverquane/go-splitflap/transition"
+)
+
+type PlaylistStep struct {
+	Dashboard    string            `json:"dashboard"`
+	DurationSecs int               `json:"duration_secs"`
+	Transition   transition.Effect `json:"transition,omitempty"` // overrides the dashboard's own transition
+}
+
+// Playlist is an ordered list of dashboards that the display rotates through, showing each for its own dwell time
+type Playlist struct {
+	Steps   []PlaylistStep `json:"steps"`
+	Loop    bool           `json:"loop"`
+	Shuffle bool           `json:"shuffle"`
+
+	order      []int
+	idx        int
+	nextStepAt time.Time
+}
+
+func (p *Playlist) Check(dashboards map[string]*Dashboard) error {
+	if len(p.Steps) == 0 {
+		return errors.New("playlist must have at least one step")
+	}
+	for _, step := range p.Steps {
+		if _, ok := dashboards[step.Dashboard]; !ok {
+			return errors.New("playlist references a dashboard that doesn't exist: " + step.Dashboard)
+		}
+		if step.DurationSecs < 1 {
+			return errors.New("playlist step duration must be at least 1 second")
+		}
+		if err := transition.Check(step.Transition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Playlist) uses(dashboard string) bool {
+	for _, step := range p.Steps {
+		if step.Dashboard == dashboard {
+			return true
+		}
+	}
+	return false
+}
+
+// reset prepares the playlist to be played from the beginning, shuffling the order of the steps if requested
+func (p *Playlist) reset() {
+	p.order = make([]int, len(p.Steps))
+	for i := range p.order {
+		p.order[i] = i
+	}
+	if p.Shuffle {
+		rand.Shuffle(len(p.order), func(i, j int) {
+			p.order[i], p.order[j] = p.order[j], p.order[i]
+		})
+	}
+	p.idx = -1
+	p.nextStepAt = time.Time{}
+}
+
+// advance moves to the next step if the current one has been displayed for long enough. It returns the step that
+// should now be shown, whether that step changed, and whether the playlist has finished entirely
+func (p *Playlist) advance(now time.Time) (step PlaylistStep, changed bool, done bool) {
+	if p.idx >= 0 && now.Before(p.nextStepAt) {
+		return p.Steps[p.order[p.idx]], false, false
+	}
+
+	p.idx++
+	if p.idx >= len(p.order) {
+		if !p.Loop {
+			p.idx = len(p.order) - 1
+			return p.Steps[p.order[p.idx]], false, true
+		}
+		// reshuffle (if enabled) every time we wrap around
+		p.reset()
+		p.idx = 0
+	}
+
+	step = p.Steps[p.order[p.idx]]
+	p.nextStepAt = now.Add(time.Duration(step.DurationSecs) * time.Second)
+	return step, true, false
+}
+
+// currentStep returns the index (into Steps) of the step currently being shown, or -1 if none is
+func (p *Playlist) currentStep() int {
+	if p.idx < 0 || p.idx >= len(p.order) {
+		return -1
+	}
+	return p.order[p.idx]
+}
+
+// ActivePlaylist returns the name of the running playlist ("" if none), and the index of the step it is showing
+func (d *Display) ActivePlaylist() (string, int) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if playlist, ok := d.Playlists[d.activePlaylist]; ok {
+		return d.activePlaylist, playlist.currentStep()
+	}
+	return "", -1
+}
+
+// GetPlaylists returns a copy of every playlist
+func (d *Display) GetPlaylists() map[string]*Playlist {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	playlists := make(map[string]*Playlist, len(d.Playlists))
+	for name, playlist := range d.Playlists {
+		p := *playlist
+		playlists[name] = &p
+	}
+	return playlists
+}
+
+func (d *Display) CreateOrUpdatePlaylist(name string, playlist *Playlist) error {
+	return d.modify(func() error {
+		if err := playlist.Check(d.Dashboards); err != nil {
+			return err
+		}
+		d.Playlists[name] = playlist
+		if name == d.activePlaylist {
+			// restart the playlist so that the changed steps take effect immediately
+			return d.startPlaylist(name)
+		}
+		return nil
+	})
+}
+
+func (d *Display) DeletePlaylist(name string) error {
+	return d.modify(func() error {
+		if name == d.activePlaylist {
+			return errors.New("cannot delete currently active playlist")
+		}
+		if _, ok := d.Playlists[name]; !ok {
+			return errors.New("playlist with that name doesn't exist")
+		}
+		for scheduleName, schedule := range d.Schedules {
+			if schedule.uses("", name) {
+				return errors.New("cannot delete playlist used by schedule " + scheduleName)
+			}
+		}
+
+		delete(d.Playlists, name)
+		return nil
+	})
+}
+
+// StartPlaylist starts a playlist from its first step, replacing any dashboard or playlist that is currently active.
+// This overrides any schedule until the next schedule rule boundary
+func (d *Display) StartPlaylist(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.Playlists[name]; !ok {
+		return errors.New("playlist does not exist")
+	}
+	d.overrideSchedule()
+	return d.startPlaylist(name)
+}
+
+func (d *Display) startPlaylist(name string) error {
+	playlist, ok := d.Playlists[name]
+	if !ok {
+		return errors.New("playlist does not exist")
+	}
+	if err := playlist.Check(d.Dashboards); err != nil {
+		return err
+	}
+	playlist.reset()
+	d.activePlaylist = name
+	return d.updatePlaylist(time.Now())
+}
+
+// StopPlaylist stops the running playlist, but leaves whatever dashboard it was showing active
+func (d *Display) StopPlaylist() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.activePlaylist = ""
+}
+
+func (d *Display) updatePlaylist(now time.Time) error {
+	playlist, ok := d.Playlists[d.activePlaylist]
+	if !ok {
+		d.activePlaylist = ""
+		return errors.New("active playlist no longer exists")
+	}
+
+	step, changed, done := playlist.advance(now)
+	if done {
+		slog.Info("playlist finished", "playlist", d.activePlaylist)
+		d.activePlaylist = ""
+		return nil
+	}
+	if changed {
+		if err := d.activateDashboard(step.Dashboard); err != nil {
+			slog.Error("failed to activate playlist dashboard, stopping playlist", "playlist", d.activePlaylist, "dashboard", step.Dashboard, "error", err.Error())
+			d.activePlaylist = ""
+			return err
+		}
+		if step.Transition != transition.None {
+			d.nextTransition = step.Transition
+		}
+		d.notify()
+	}
+	return nil
+}
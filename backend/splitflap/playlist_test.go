@@ -0,0 +1,127 @@
+package splitflap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+func testPlaylistDisplay() *Display {
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	d.Dashboards["a"] = &Dashboard{Routines: []*routine.Routine{}}
+	d.Dashboards["b"] = &Dashboard{Routines: []*routine.Routine{}}
+	return d
+}
+
+func TestPlaylist_advance(t *testing.T) {
+	p := Playlist{
+		Steps: []PlaylistStep{
+			{Dashboard: "a", DurationSecs: 5},
+			{Dashboard: "b", DurationSecs: 10},
+		},
+		Loop: true,
+	}
+	p.reset()
+
+	now := time.Now()
+	step, changed, done := p.advance(now)
+	if step.Dashboard != "a" || !changed || done {
+		t.Fatal("first advance should move to the first step")
+	}
+	_, changed, _ = p.advance(now.Add(time.Second * 4))
+	if changed {
+		t.Fatal("playlist should not advance before the step duration has elapsed")
+	}
+	step, changed, _ = p.advance(now.Add(time.Second * 5))
+	if step.Dashboard != "b" || !changed {
+		t.Fatal("playlist should advance to the second step after the first step's duration")
+	}
+	step, changed, done = p.advance(now.Add(time.Second * 15))
+	if step.Dashboard != "a" || !changed || done {
+		t.Fatal("looping playlist should wrap around to the first step")
+	}
+}
+
+func TestPlaylist_advance_noLoop(t *testing.T) {
+	p := Playlist{
+		Steps: []PlaylistStep{{Dashboard: "a", DurationSecs: 1}},
+	}
+	p.reset()
+
+	now := time.Now()
+	p.advance(now)
+	step, changed, done := p.advance(now.Add(time.Second))
+	if step.Dashboard != "a" || changed || !done {
+		t.Fatal("non-looping playlist should finish on its last step")
+	}
+}
+
+func TestPlaylist_shuffle(t *testing.T) {
+	p := Playlist{
+		Steps: []PlaylistStep{
+			{Dashboard: "a", DurationSecs: 1},
+			{Dashboard: "b", DurationSecs: 1},
+			{Dashboard: "c", DurationSecs: 1},
+		},
+		Shuffle: true,
+	}
+	p.reset()
+
+	seen := make(map[string]bool)
+	now := time.Now()
+	for i := range p.Steps {
+		step, _, _ := p.advance(now.Add(time.Duration(i) * time.Second))
+		seen[step.Dashboard] = true
+	}
+	if len(seen) != len(p.Steps) {
+		t.Fatal("shuffled playlist should visit every step exactly once per pass")
+	}
+}
+
+func TestDisplay_StartPlaylist(t *testing.T) {
+	d := testPlaylistDisplay()
+	d.Playlists["morning"] = &Playlist{
+		Steps: []PlaylistStep{
+			{Dashboard: "a", DurationSecs: 5},
+			{Dashboard: "b", DurationSecs: 5},
+		},
+	}
+
+	if err := d.StartPlaylist("morning"); err != nil {
+		t.Fatal(err)
+	}
+	if d.ActiveDashboard() != "a" {
+		t.Fatal("starting a playlist should activate its first dashboard")
+	}
+	if name, step := d.ActivePlaylist(); name != "morning" || step != 0 {
+		t.Fatal("active playlist should be reported as started on step 0")
+	}
+
+	if err := d.updatePlaylist(time.Now().Add(time.Second * 5)); err != nil {
+		t.Fatal(err)
+	}
+	if d.ActiveDashboard() != "b" {
+		t.Fatal("playlist should have rotated to the second dashboard")
+	}
+
+	if err := d.ActivateDashboard("a"); err != nil {
+		t.Fatal(err)
+	}
+	if name, _ := d.ActivePlaylist(); name != "" {
+		t.Fatal("manually activating a dashboard should stop the playlist")
+	}
+}
+
+func TestPlaylist_Check(t *testing.T) {
+	d := testPlaylistDisplay()
+	p := Playlist{Steps: []PlaylistStep{{Dashboard: "missing", DurationSecs: 5}}}
+	if p.Check(d.Dashboards) == nil {
+		t.Fatal("playlist referencing a missing dashboard should fail validation")
+	}
+	p = Playlist{Steps: []PlaylistStep{{Dashboard: "a", DurationSecs: 0}}}
+	if p.Check(d.Dashboards) == nil {
+		t.Fatal("playlist with a zero duration step should fail validation")
+	}
+}
@@ -0,0 +1,200 @@
+package splitflap
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"github.com/denverquane/go-splitflap/provider"
+)
+
+// ProviderStatus is a provider's config, whether it is running, and why its config is invalid if it is
+type ProviderStatus struct {
+	*provider.Provider
+	Running bool   `json:"running"`
+	Error   string `json:"error,omitempty"`
+}
+
+// GetProviders returns every provider, and whether it is running
+func (h *Hub) GetProviders() map[string]ProviderStatus {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	providers := make(map[string]ProviderStatus, len(h.Providers))
+	for name, prov := range h.Providers {
+		status := ProviderStatus{Provider: prov, Running: h.running[name]}
+		if err := h.invalid[name]; err != nil {
+			status.Error = err.Error()
+		}
+		providers[name] = status
+	}
+	return providers
+}
+
+// ProviderValues returns the values a provider currently supplies
+func (h *Hub) ProviderValues(name string) (provider.PValues, error) {
+	h.lock.Lock()
+	prov, ok := h.Providers[name]
+	h.lock.Unlock()
+
+	if !ok {
+		return nil, errors.New("provider does not exist")
+	}
+	return prov.Provider.Values(), nil
+}
+
+// CreateOrUpdateProvider adds a provider, or replaces the config of an existing one, and saves the hub. New providers,
+// and those replacing an invalid config, are started; a replaced provider is stopped, and otherwise the new one is only
+// started if the old one was running. Either way
+// it polls at its active rate if an active dashboard already uses it
+func (h *Hub) CreateOrUpdateProvider(name string, prov *provider.Provider) error {
+	if name == "" {
+		return errors.New("provider name cannot be empty")
+	}
+	if prov.Provider == nil {
+		return errors.New("provider config cannot be empty")
+	}
+	if err := prov.Check(); err != nil {
+		return err
+	}
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+	if err := h.checkFile(); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	old, exists := h.Providers[name]
+	wasRunning := h.running[name]
+	start := !exists || wasRunning || h.invalid[name] != nil
+	h.lock.Unlock()
+
+	if start {
+		if err := h.startProvider(name, prov); err != nil {
+			return err
+		}
+	}
+	h.lock.Lock()
+	h.Providers[name] = prov
+	delete(h.invalid, name)
+	h.lock.Unlock()
+
+	// stopping can take a moment, so it's done without holding up the displays
+	if wasRunning {
+		old.Provider.Stop()
+	}
+	return h.save()
+}
+
+// DeleteProvider stops and removes a provider, and saves the hub. Providers that are used by a routine can't be
+// deleted
+func (h *Hub) DeleteProvider(name string) error {
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+	if err := h.checkFile(); err != nil {
+		return err
+	}
+	if user := h.providerUser(name); user != "" {
+		return errors.New("cannot delete provider used by " + user)
+	}
+
+	h.lock.Lock()
+	prov, ok := h.Providers[name]
+	running := h.running[name]
+	delete(h.Providers, name)
+	delete(h.providerUsers, name)
+	delete(h.running, name)
+	delete(h.invalid, name)
+	h.lock.Unlock()
+
+	if !ok {
+		return errors.New("provider does not exist")
+	}
+	if running {
+		prov.Provider.Stop()
+	}
+	return h.save()
+}
+
+// StartProvider starts a stopped provider. It polls at its active rate if a display is using it, otherwise at its
+// background rate
+func (h *Hub) StartProvider(name string) error {
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+
+	h.lock.Lock()
+	prov, ok := h.Providers[name]
+	running := h.running[name]
+	invalid := h.invalid[name]
+	h.lock.Unlock()
+
+	if !ok {
+		return errors.New("provider does not exist")
+	}
+	if running {
+		return errors.New("provider is already running")
+	}
+	if invalid != nil {
+		return errors.New("provider config is invalid: " + invalid.Error())
+	}
+	return h.startProvider(name, prov)
+}
+
+// StopProvider stops a running provider. Routines that use it keep showing the last values it supplied. Whether a
+// provider is running isn't saved, so it is started again when the backend restarts
+func (h *Hub) StopProvider(name string) error {
+	h.lock.Lock()
+	prov, ok := h.Providers[name]
+	running := h.running[name]
+	if running {
+		h.running[name] = false
+	}
+	h.lock.Unlock()
+
+	if !ok {
+		return errors.New("provider does not exist")
+	}
+	if !running {
+		return errors.New("provider is not running")
+	}
+	prov.Provider.Stop()
+	return nil
+}
+
+// startProvider starts a provider at the poll rate it should have, and records it as running under name. Starting can
+// block (e.g. while connecting to a broker), so it must be called with writeLock held and the lock not held, so every
+// display keeps ticking
+func (h *Hub) startProvider(name string, prov *provider.Provider) error {
+	if err := startProvider(name, prov); err != nil {
+		return err
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.Providers[name] = prov
+	h.running[name] = true
+	// a display may have started using it while it was starting
+	if len(h.providerUsers[name]) > 0 {
+		prov.Provider.SetPollRateSecs(prov.ActivePollRateSecs)
+	}
+	return nil
+}
+
+// providerUser returns a display and dashboard that has a routine using a provider, or "" if none does
+func (h *Hub) providerUser(name string) string {
+	for _, id := range h.DisplayIDs() {
+		d, _ := h.Display(id)
+		d.lock.RLock()
+		dashboards := slices.Sorted(maps.Keys(d.Dashboards))
+		for _, dashboardName := range dashboards {
+			for _, rout := range d.Dashboards[dashboardName].Routines {
+				if rout.Routine.GetProviderName() == name {
+					d.lock.RUnlock()
+					return "dashboard " + dashboardName + " of display " + id
+				}
+			}
+		}
+		d.lock.RUnlock()
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package splitflap
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+func TestHub_providerLifecycle(t *testing.T) {
+	hub, path := loadReloadTestHub(t)
+	d, _ := hub.Display(DefaultDisplayID)
+	if err := d.AddRoutineToDashboard("main", routine.Routine{
+		RoutineBase: routine.RoutineBase{Type: routine.TEMPERATURE, Size: display.Size{Width: 4, Height: 1}},
+		Routine:     &routine.TemperatureRoutine{ProviderName: "kept"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hub.CreateOrUpdateProvider("added", &provider.Provider{Type: reloadTestProvider, Provider: &reloadProvider{}}); err == nil {
+		t.Fatal("a provider with an invalid config should be rejected")
+	}
+	added := &reloadProvider{Name: "added"}
+	if err := hub.CreateOrUpdateProvider("added", &provider.Provider{Type: reloadTestProvider, Provider: added}); err != nil {
+		t.Fatal(err)
+	}
+	if !added.started || !hub.GetProviders()["added"].Running {
+		t.Fatal("a new provider should be started")
+	}
+
+	if err := hub.StopProvider("added"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.StopProvider("added"); err == nil {
+		t.Fatal("stopping a stopped provider should fail")
+	}
+	replaced := &reloadProvider{Name: "replaced"}
+	if err := hub.CreateOrUpdateProvider("added", &provider.Provider{Type: reloadTestProvider, Provider: replaced}); err != nil {
+		t.Fatal(err)
+	}
+	if replaced.started || hub.GetProviders()["added"].Running {
+		t.Fatal("replacing a stopped provider shouldn't start it")
+	}
+	if err := hub.StartProvider("added"); err != nil || !replaced.started {
+		t.Fatal("a stopped provider should start again", err)
+	}
+
+	if err := hub.DeleteProvider("kept"); err == nil {
+		t.Fatal("a provider used by a routine shouldn't be deleted")
+	}
+	if err := hub.DeleteProvider("changed"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hub.ProviderValues("changed"); err == nil {
+		t.Fatal("a deleted provider shouldn't have values")
+	}
+
+	loaded, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Providers["changed"]; ok || loaded.Providers["added"].Provider.(*reloadProvider).Name != "replaced" {
+		t.Fatal("provider changes should be saved")
+	}
+}
+
+func TestHub_providerCreatedWhileInUse(t *testing.T) {
+	hub, _ := loadReloadTestHub(t)
+	d, _ := hub.Display(DefaultDisplayID)
+	if err := d.AddRoutineToDashboard("main", routine.Routine{
+		RoutineBase: routine.RoutineBase{Type: routine.TEMPERATURE, Size: display.Size{Width: 4, Height: 1}},
+		Routine:     &routine.TemperatureRoutine{ProviderName: "later"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ActivateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	later := &reloadProvider{Name: "later"}
+	prov := &provider.Provider{Type: reloadTestProvider, ActivePollRateSecs: 5, BackgroundPollRateSecs: 600, Provider: later}
+	if err := hub.CreateOrUpdateProvider("later", prov); err != nil {
+		t.Fatal(err)
+	}
+	if later.rate != 5 {
+		t.Fatal("a provider the active dashboard already uses should poll at its active rate, got", later.rate)
+	}
+
+	d.DeactivateActiveDashboard()
+	if later.rate != 600 {
+		t.Fatal("a provider no dashboard uses should poll at its background rate, got", later.rate)
+	}
+}
+
+func TestHub_invalidProvider(t *testing.T) {
+	// e.g. a provider saved by a version that didn't check its config as strictly
+	path := writeTestFile(t, strings.Replace(reloadDisplayJSON, `"name": "before"`, `"name": ""`, 1))
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal("an invalid provider shouldn't stop the config loading", err)
+	}
+	if err = hub.StartProviders(); err != nil {
+		t.Fatal(err)
+	}
+	status := hub.GetProviders()["changed"]
+	if status.Running || status.Error == "" || hub.Providers["changed"].Provider.(*reloadProvider).started {
+		t.Fatal("an invalid provider should be disabled, with the reason", status)
+	}
+	if !hub.GetProviders()["kept"].Running {
+		t.Fatal("the valid providers should still start")
+	}
+	if err = hub.StartProvider("changed"); err == nil {
+		t.Fatal("an invalid provider shouldn't be started")
+	}
+
+	// changes to the rest of the file can still be reloaded
+	edited := strings.Replace(reloadDisplayJSON, `"name": "before"`, `"name": ""`, 1)
+	if err = os.WriteFile(path, []byte(strings.Replace(edited, `"text": "HI"`, `"text": "BYE"`, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = hub.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if hub.GetProviders()["changed"].Error == "" {
+		t.Fatal("a reloaded provider should stay disabled until it is fixed")
+	}
+
+	fixed := &reloadProvider{Name: "fixed"}
+	if err = hub.CreateOrUpdateProvider("changed", &provider.Provider{Type: reloadTestProvider, Provider: fixed}); err != nil {
+		t.Fatal(err)
+	}
+	if status = hub.GetProviders()["changed"]; !status.Running || status.Error != "" || !fixed.started {
+		t.Fatal("fixing an invalid provider should start it", status)
+	}
+}
+
+// blockingProvider takes until it is released to start, like a provider connecting to a broker that is down
+type blockingProvider struct {
+	pollRateProvider
+	starting chan struct{}
+	release  chan struct{}
+}
+
+func (p *blockingProvider) Start() error {
+	close(p.starting)
+	<-p.release
+	return nil
+}
+
+func TestHub_StartProviders_blocking(t *testing.T) {
+	slow := &blockingProvider{starting: make(chan struct{}), release: make(chan struct{})}
+	hub := NewHub()
+	hub.Providers["slow"] = &provider.Provider{ActivePollRateSecs: 5, BackgroundPollRateSecs: 600, Provider: slow}
+	d := NewDisplay(display.Size{Width: 4, Height: 1})
+	if err := hub.AddDisplay(DefaultDisplayID, d); err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan error)
+	go func() {
+		started <- hub.StartProviders()
+	}()
+	<-slow.starting
+
+	// displays use providers as they tick, which shouldn't wait for a provider to start
+	used := make(chan struct{})
+	go func() {
+		hub.useProvider(d, "slow", true)
+		close(used)
+	}()
+	select {
+	case <-used:
+	case <-time.After(time.Second * 2):
+		t.Fatal("a provider that is starting shouldn't hold up the displays")
+	}
+
+	close(slow.release)
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	if !hub.GetProviders()["slow"].Running || slow.pollRateSecs != 5 {
+		t.Fatal("a provider a display started using while it was starting should poll at its active rate", slow.pollRateSecs)
+	}
+}
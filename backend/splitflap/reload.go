@@ -0,0 +1,203 @@
+package splitflap
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/denverquane/go-splitflap/provider"
+)
+
+// ErrConfigChanged is returned when the config file was changed on disk (e.g. edited by hand, or deployed by config
+// management) and hasn't been reloaded yet. Saving now would overwrite the change, so nothing is saved until the file
+// has been reloaded
+var ErrConfigChanged = errors.New("the config file was changed on disk and hasn't been reloaded yet; try again once it has")
+
+// WatchFile reloads the hub whenever its file changes on disk, checking every interval, for as long as the process
+// runs. A file that can't be reloaded is logged once, and the running config is kept until the file is fixed
+func (h *Hub) WatchFile(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var rejected [sha256.Size]byte
+	for range ticker.C {
+		h.writeLock.Lock()
+		hash, err := hashFile(h.filepath)
+		h.writeLock.Unlock()
+		if err != nil || hash == rejected {
+			continue
+		}
+		if err = h.Reload(); err != nil {
+			rejected = hash
+			slog.Error("Failed to reload config file, keeping the running config", "file", h.filepath, "error", err.Error())
+		}
+	}
+}
+
+// Reload reads the hub's file again, if it changed since it was last loaded or saved, and applies it. The file is
+// validated as a whole before anything is applied: if any of it is invalid, nothing changes. The only exception is a
+// provider that was already invalid when it was loaded, which stays disabled until it is fixed. The displays themselves
+// (their IDs, sizes, ports, layouts and poll rates) can't change while running, and need a restart
+func (h *Hub) Reload() error {
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+
+	bytes, err := os.ReadFile(h.filepath)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(bytes)
+	if hash == h.fileHash {
+		return nil
+	}
+	next, version, err := parseHubFile(bytes)
+	if err != nil {
+		return err
+	}
+	if err = h.checkReload(next); err != nil {
+		return err
+	}
+
+	// providers whose config didn't change keep running, so they don't have to fetch everything again
+	h.lock.Lock()
+	current := h.Providers
+	h.lock.Unlock()
+	// a provider that was already invalid when it was loaded stays disabled, but a change can't make one invalid
+	for _, name := range slices.Sorted(maps.Keys(next.invalid)) {
+		if old, ok := current[name]; !ok || !sameProvider(old, next.Providers[name]) {
+			return errors.New("provider " + strconv.Quote(name) + ": " + next.invalid[name].Error())
+		}
+	}
+	providers := make(map[string]*provider.Provider, len(next.Providers))
+	var started []*provider.Provider
+	for name, prov := range next.Providers {
+		if old, ok := current[name]; ok && sameProvider(old, prov) {
+			providers[name] = old
+			continue
+		}
+		if err = startProvider(name, prov); err != nil {
+			for _, s := range started {
+				go s.Provider.Stop()
+			}
+			return err
+		}
+		started = append(started, prov)
+		providers[name] = prov
+	}
+
+	h.swap(next, providers, started)
+	h.fileHash = hash
+	slog.Info("Reloaded config file", "file", h.filepath)
+	if version < SchemaVersion {
+		return h.upgradeFile(bytes, version)
+	}
+	return nil
+}
+
+// swap replaces the hub's providers, and the config of each display, with those of next
+func (h *Hub) swap(next *Hub, providers map[string]*provider.Provider, started []*provider.Provider) {
+	ids := h.DisplayIDs()
+	displays := make([]*Display, len(ids))
+	for i, id := range ids {
+		displays[i], _ = h.Display(id)
+		displays[i].lock.Lock()
+		defer displays[i].lock.Unlock()
+	}
+
+	// every display lets go of its providers while they are swapped, then picks up where it was
+	type resume struct{ dashboard, playlist string }
+	resumes := make([]resume, len(displays))
+	for i, d := range displays {
+		resumes[i] = resume{d.activeDashboard, d.activePlaylist}
+		d.activePlaylist = ""
+		d.deactivateActiveDashboard()
+	}
+
+	h.lock.Lock()
+	var stopped []*provider.Provider
+	for name, prov := range h.Providers {
+		if providers[name] != prov && h.running[name] {
+			stopped = append(stopped, prov)
+		}
+		delete(h.Providers, name)
+		delete(h.providerUsers, name)
+	}
+	for name, prov := range providers {
+		h.Providers[name] = prov
+		if slices.Contains(started, prov) {
+			h.running[name] = true
+		}
+	}
+	for name := range h.running {
+		if _, ok := providers[name]; !ok {
+			delete(h.running, name)
+		}
+	}
+	h.invalid = next.invalid
+	h.lock.Unlock()
+	for _, prov := range stopped {
+		go prov.Provider.Stop()
+	}
+
+	for i, d := range displays {
+		d.reload(next.Displays[ids[i]], resumes[i].dashboard, resumes[i].playlist)
+	}
+}
+
+// checkReload makes sure the only changes in next are ones that can be applied while the displays are running
+func (h *Hub) checkReload(next *Hub) error {
+	ids := h.DisplayIDs()
+	if nextIDs := next.DisplayIDs(); !slices.Equal(ids, nextIDs) {
+		return errors.New("the displays were added or removed, which needs a restart")
+	}
+	for _, id := range ids {
+		d, _ := h.Display(id)
+		nd, _ := next.Display(id)
+		d.lock.RLock()
+		changed := d.Size != nd.Size || d.Port != nd.Port || !slices.Equal(d.Layout, nd.Layout) || d.PollRate != nd.PollRate
+		d.lock.RUnlock()
+		if changed {
+			return errors.New("the size, port, layout or poll rate of display " + id + " changed, which needs a restart")
+		}
+	}
+	return nil
+}
+
+func sameProvider(a, b *provider.Provider) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
+
+// reload replaces the display's dashboards, playlists, schedules, translations and motion policy with those of next,
+// then resumes the dashboard or playlist it was showing, if it still exists. Must be called with the lock held, and
+// with the display's providers released
+func (d *Display) reload(next *Display, dashboard, playlist string) {
+	d.Translations = next.Translations
+	d.Dashboards = next.Dashboards
+	d.Playlists = next.Playlists
+	d.Schedules = next.Schedules
+	d.ScheduleTimezone = next.ScheduleTimezone
+	d.scheduleLoc = next.scheduleLoc
+	d.MotionPolicy = next.MotionPolicy
+	if _, ok := d.Schedules[d.activeSchedule]; !ok {
+		d.activeSchedule = ""
+	}
+
+	var err error
+	if _, ok := d.Playlists[playlist]; ok {
+		err = d.startPlaylist(playlist)
+	} else if _, ok = d.Dashboards[dashboard]; ok {
+		err = d.activateDashboard(dashboard)
+	}
+	if err != nil {
+		slog.Error("Failed to resume after reloading the config file", "dashboard", dashboard, "playlist", playlist, "error", err.Error())
+	}
+	d.notify()
+}
@@ -0,0 +1,158 @@
+package splitflap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/provider"
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+const reloadTestProvider provider.ProviderType = "RELOAD_TEST"
+
+// reloadProvider is a provider that does nothing, so config files can be reloaded without reaching out to any service
+type reloadProvider struct {
+	Name string `json:"name"`
+
+	started bool
+	rate    int
+}
+
+func (p *reloadProvider) Check() error {
+	if p.Name == "" {
+		return errors.New("name must be set")
+	}
+	return nil
+}
+func (p *reloadProvider) Start() error                     { p.started = true; return nil }
+func (p *reloadProvider) SetPollRateSecs(rate int)         { p.rate = rate }
+func (p *reloadProvider) Stop()                            {}
+func (p *reloadProvider) Values() provider.PValues         { return nil }
+func (p *reloadProvider) Parameters() []provider.Parameter { return nil }
+
+func init() {
+	provider.AllProviders[reloadTestProvider] = &reloadProvider{}
+}
+
+const reloadDisplayJSON = `{
+  "schema_version": 3,
+  "providers": {
+    "kept": {"type": "RELOAD_TEST", "config": {"name": "kept"}},
+    "changed": {"type": "RELOAD_TEST", "config": {"name": "before"}}
+  },
+  "displays": {"default": {
+    "size": {"width": 4, "height": 1},
+    "layout": [0, 1, 2, 3],
+    "poll_rate_ms": 100,
+    "translations": {},
+    "dashboards": {"main": {"routines": [
+      {"type": "TEXT", "location": {"x": 0, "y": 0}, "size": {"width": 4, "height": 1}, "config": {"text": "HI"}}
+    ]}}
+  }}
+}`
+
+func loadReloadTestHub(t *testing.T) (*Hub, string) {
+	path := writeTestFile(t, reloadDisplayJSON)
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = hub.StartProviders(); err != nil {
+		t.Fatal(err)
+	}
+	return hub, path
+}
+
+func TestHub_Reload(t *testing.T) {
+	hub, path := loadReloadTestHub(t)
+	d, _ := hub.Display(DefaultDisplayID)
+	if err := d.ActivateDashboard("main"); err != nil {
+		t.Fatal(err)
+	}
+	kept := hub.Providers["kept"]
+	changed := hub.Providers["changed"]
+
+	edited := strings.Replace(reloadDisplayJSON, `"name": "before"`, `"name": "after"`, 1)
+	edited = strings.Replace(edited, `"translations": {}`, `"translations": {"97": 65}`, 1)
+	edited = strings.Replace(edited, `"text": "HI"`, `"text": "BYE"`, 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateDashboard("stale"); !errors.Is(err, ErrConfigChanged) {
+		t.Fatal("changes shouldn't be made while the file has changes that haven't been reloaded", err)
+	}
+
+	if err := hub.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Providers["kept"] != kept {
+		t.Fatal("a provider whose config didn't change should keep running")
+	}
+	if hub.Providers["changed"] == changed || !hub.Providers["changed"].Provider.(*reloadProvider).started {
+		t.Fatal("a provider whose config changed should be replaced, and started")
+	}
+	if d.GetTranslations()['a'] != 'A' {
+		t.Fatal("translations should be reloaded")
+	}
+	if d.ActiveDashboard() != "main" {
+		t.Fatal("the active dashboard should still be active after reloading")
+	}
+	if d.GetDashboards()["main"].Routines[0].Routine.(*routine.TextRoutine).Text != "BYE" {
+		t.Fatal("dashboards should be reloaded")
+	}
+
+	// the reloaded file is what changes are saved on top of
+	if err := d.CreateDashboard("other"); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := loaded.Display(DefaultDisplayID); len(reloaded.Dashboards) != 2 || reloaded.Translations['a'] != 'A' {
+		t.Fatal("saving after a reload should keep the reloaded config")
+	}
+}
+
+func TestHub_Reload_invalid(t *testing.T) {
+	hub, path := loadReloadTestHub(t)
+	d, _ := hub.Display(DefaultDisplayID)
+
+	for _, edit := range []struct{ old, new string }{
+		{`"name": "before"`, `"name": ""`},             // provider that fails its check
+		{`"type": "TEXT"`, `"type": "NOPE"`},           // unknown routine
+		{`"layout": [0, 1, 2, 3]`, `"layout": [0]`},    // invalid layout
+		{`"poll_rate_ms": 100`, `"poll_rate_ms": 200`}, // needs a restart
+	} {
+		if err := os.WriteFile(path, []byte(strings.Replace(reloadDisplayJSON, edit.old, edit.new, 1)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := hub.Reload(); err == nil {
+			t.Fatal("reloading should fail after changing", edit.old, "to", edit.new)
+		}
+		if hub.Providers["changed"].Provider.(*reloadProvider).Name != "before" || len(d.GetDashboards()) != 1 {
+			t.Fatal("nothing should change when a reload fails")
+		}
+		if err := d.CreateDashboard("other"); !errors.Is(err, ErrConfigChanged) {
+			t.Fatal("an invalid file shouldn't be overwritten", err)
+		}
+	}
+
+	// once the file is fixed, it's reloaded and saving works again
+	if err := os.WriteFile(path, []byte(reloadDisplayJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateDashboard("other"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatal("saving shouldn't leave temporary files behind", entries)
+	}
+}
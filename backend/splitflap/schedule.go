@@ -0,0 +1,335 @@
+package splitflap
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SUN": time.Sunday,
+	"MON": time.Monday,
+	"TUE": time.Tuesday,
+	"WED": time.Wednesday,
+	"THU": time.Thursday,
+	"FRI": time.Friday,
+	"SAT": time.Saturday,
+}
+
+// Schedule is a rule that activates a dashboard (or playlist) at a certain time of day, on certain days of the week.
+// A Schedule without an End stays in effect until another rule starts. Once no rule is in effect, the display goes
+// back to what it showed before the schedule took over. A Schedule with neither a Dashboard nor a
+// Playlist blanks the display
+type Schedule struct {
+	Days      []string `json:"days"`  // three-letter weekday names ("MON", "TUE", ...), or empty for every day
+	Start     string   `json:"start"` // HH:MM, 24-hour
+	End       string   `json:"end"`   // HH:MM, 24-hour. Optional; if earlier than Start, the rule runs past midnight
+	Dashboard string   `json:"dashboard"`
+	Playlist  string   `json:"playlist"`
+
+	days     map[time.Weekday]bool
+	startMin int
+	endMin   int
+}
+
+// scheduleRestore is what a display showed before its schedule took over, to go back to once no rule is in effect
+type scheduleRestore struct {
+	dashboard string
+	playlist  string
+}
+
+func parseTimeOfDay(str string) (int, error) {
+	t, err := time.Parse("15:04", str)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day \"%s\", expected HH:MM", str)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (s *Schedule) Check(dashboards map[string]*Dashboard, playlists map[string]*Playlist) error {
+	if s.Dashboard != "" && s.Playlist != "" {
+		return errors.New("schedule cannot specify both a dashboard and a playlist")
+	}
+	if s.Dashboard != "" {
+		if _, ok := dashboards[s.Dashboard]; !ok {
+			return errors.New("schedule references a dashboard that doesn't exist: " + s.Dashboard)
+		}
+	}
+	if s.Playlist != "" {
+		if _, ok := playlists[s.Playlist]; !ok {
+			return errors.New("schedule references a playlist that doesn't exist: " + s.Playlist)
+		}
+	}
+
+	days := make(map[time.Weekday]bool)
+	for _, day := range s.Days {
+		if wd, ok := weekdays[strings.ToUpper(day)]; !ok {
+			return errors.New("invalid day in schedule: " + day)
+		} else {
+			days[wd] = true
+		}
+	}
+	if len(days) == 0 {
+		for _, wd := range weekdays {
+			days[wd] = true
+		}
+	}
+
+	startMin, err := parseTimeOfDay(s.Start)
+	if err != nil {
+		return err
+	}
+	endMin := -1
+	if s.End != "" {
+		endMin, err = parseTimeOfDay(s.End)
+		if err != nil {
+			return err
+		}
+		if endMin == startMin {
+			return errors.New("schedule start and end cannot be the same time")
+		}
+	}
+
+	s.days = days
+	s.startMin = startMin
+	s.endMin = endMin
+	return nil
+}
+
+func (s *Schedule) uses(dashboard, playlist string) bool {
+	return (dashboard != "" && s.Dashboard == dashboard) || (playlist != "" && s.Playlist == playlist)
+}
+
+func atMinute(day time.Time, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, day.Location())
+}
+
+// occurrence returns the start and end of the rule on a given day (end is zero if the rule has no End)
+func (s *Schedule) occurrence(day time.Time) (time.Time, time.Time) {
+	start := atMinute(day, s.startMin)
+	if s.endMin < 0 {
+		return start, time.Time{}
+	}
+	end := atMinute(day, s.endMin)
+	if s.endMin < s.startMin {
+		end = atMinute(day.AddDate(0, 0, 1), s.endMin)
+	}
+	return start, end
+}
+
+// lastStart returns when the rule most recently started at or before now, if it is still in effect
+func (s *Schedule) lastStart(now time.Time) (time.Time, bool) {
+	for offset := 0; offset <= 7; offset++ {
+		day := now.AddDate(0, 0, -offset)
+		if !s.days[day.Weekday()] {
+			continue
+		}
+		start, end := s.occurrence(day)
+		if start.After(now) {
+			continue
+		}
+		if !end.IsZero() && !now.Before(end) {
+			return time.Time{}, false
+		}
+		return start, true
+	}
+	return time.Time{}, false
+}
+
+// scheduleInEffect returns the name of the rule that should be in effect at the given time ("" if none), which is the
+// rule that started most recently and hasn't ended yet
+func scheduleInEffect(schedules map[string]*Schedule, now time.Time) string {
+	names := make([]string, 0, len(schedules))
+	for name := range schedules {
+		names = append(names, name)
+	}
+	// sort so that ties are resolved the same way every time
+	sort.Strings(names)
+
+	active := ""
+	var activeStart time.Time
+	for _, name := range names {
+		if start, ok := schedules[name].lastStart(now); ok && (active == "" || start.After(activeStart)) {
+			active = name
+			activeStart = start
+		}
+	}
+	return active
+}
+
+// nextScheduleBoundary returns the next time after now that any rule starts or ends (zero if there are no rules)
+func nextScheduleBoundary(schedules map[string]*Schedule, now time.Time) time.Time {
+	var next time.Time
+	for _, s := range schedules {
+		for offset := -1; offset <= 7; offset++ {
+			day := now.AddDate(0, 0, offset)
+			if !s.days[day.Weekday()] {
+				continue
+			}
+			start, end := s.occurrence(day)
+			for _, t := range []time.Time{start, end} {
+				if t.After(now) && (next.IsZero() || t.Before(next)) {
+					next = t
+				}
+			}
+		}
+	}
+	return next
+}
+
+// ActiveSchedule returns the name of the schedule rule that is in effect ("" if none), and when any manual override
+// of the schedule expires
+func (d *Display) ActiveSchedule() (string, time.Time) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.activeSchedule, d.scheduleOverrideUntil
+}
+
+// GetSchedules returns a copy of every schedule rule
+func (d *Display) GetSchedules() map[string]*Schedule {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	schedules := make(map[string]*Schedule, len(d.Schedules))
+	for name, schedule := range d.Schedules {
+		s := *schedule
+		schedules[name] = &s
+	}
+	return schedules
+}
+
+func (d *Display) GetScheduleTimezone() string {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.ScheduleTimezone
+}
+
+func (d *Display) CreateOrUpdateSchedule(name string, schedule *Schedule) error {
+	return d.modify(func() error {
+		if err := schedule.Check(d.Dashboards, d.Playlists); err != nil {
+			return err
+		}
+		d.Schedules[name] = schedule
+		// force the rules to be re-evaluated
+		d.activeSchedule = ""
+		return nil
+	})
+}
+
+func (d *Display) DeleteSchedule(name string) error {
+	return d.modify(func() error {
+		if _, ok := d.Schedules[name]; !ok {
+			return errors.New("schedule with that name doesn't exist")
+		}
+
+		delete(d.Schedules, name)
+		if name == d.activeSchedule {
+			d.activeSchedule = ""
+		}
+		return nil
+	})
+}
+
+func (d *Display) SetScheduleTimezone(timezone string) error {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+	return d.modify(func() error {
+		d.ScheduleTimezone = timezone
+		d.scheduleLoc = loc
+		d.activeSchedule = ""
+		return nil
+	})
+}
+
+// overrideSchedule is called when the display is changed manually, so that the schedule doesn't immediately revert the
+// change. The schedule resumes at the next rule boundary
+func (d *Display) overrideSchedule() {
+	if len(d.Schedules) == 0 {
+		return
+	}
+	d.scheduleOverrideUntil = nextScheduleBoundary(d.Schedules, time.Now().In(d.scheduleLoc))
+	d.activeSchedule = ""
+	// the manual change is what the display keeps showing once the schedule's rules are over
+	d.beforeSchedule = nil
+}
+
+// updateSchedule applies whichever rule is in effect, if it changed since it was last applied. Once no rule is in
+// effect any more, the display goes back to what it showed before the schedule took over. It returns true if the
+// display should be blanked
+func (d *Display) updateSchedule(now time.Time) bool {
+	if (len(d.Schedules) == 0 && d.beforeSchedule == nil) || now.Before(d.scheduleOverrideUntil) {
+		return false
+	}
+
+	name := ""
+	if len(d.Schedules) > 0 {
+		name = scheduleInEffect(d.Schedules, now.In(d.scheduleLoc))
+	}
+	if name == "" {
+		return d.endSchedule()
+	}
+	if name == d.activeSchedule {
+		return false
+	}
+	if d.beforeSchedule == nil {
+		d.beforeSchedule = &scheduleRestore{dashboard: d.activeDashboard, playlist: d.activePlaylist}
+	}
+	d.activeSchedule = name
+	schedule := d.Schedules[name]
+	slog.Info("schedule rule started", "schedule", name, "dashboard", schedule.Dashboard, "playlist", schedule.Playlist)
+
+	var err error
+	switch {
+	case schedule.Playlist != "":
+		err = d.startPlaylist(schedule.Playlist)
+	case schedule.Dashboard != "":
+		d.activePlaylist = ""
+		err = d.activateDashboard(schedule.Dashboard)
+	default:
+		d.activePlaylist = ""
+		d.deactivateActiveDashboard()
+	}
+	if err != nil {
+		slog.Error("failed to apply schedule", "schedule", name, "error", err.Error())
+	}
+	d.notify()
+	return schedule.Playlist == "" && schedule.Dashboard == ""
+}
+
+// endSchedule stops the rule that was in effect, and restores the dashboard or playlist the display showed before the
+// schedule took over, if it still exists. It returns true if the display should be blanked
+func (d *Display) endSchedule() bool {
+	if d.activeSchedule == "" && d.beforeSchedule == nil {
+		return false
+	}
+	slog.Info("schedule rule ended", "schedule", d.activeSchedule)
+	restore := d.beforeSchedule
+	d.activeSchedule = ""
+	d.beforeSchedule = nil
+	d.activePlaylist = ""
+
+	var err error
+	blank := false
+	switch {
+	case restore == nil:
+		d.deactivateActiveDashboard()
+		blank = true
+	case d.Playlists[restore.playlist] != nil:
+		err = d.startPlaylist(restore.playlist)
+	case d.Dashboards[restore.dashboard] != nil:
+		err = d.activateDashboard(restore.dashboard)
+	default:
+		d.deactivateActiveDashboard()
+		blank = true
+	}
+	if err != nil {
+		slog.Error("failed to restore the display after the schedule", "dashboard", restore.dashboard, "playlist", restore.playlist, "error", err.Error())
+	}
+	d.notify()
+	return blank
+}
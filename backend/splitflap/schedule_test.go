@@ -0,0 +1,118 @@
+package splitflap
+
+import (
+	"testing"
+	"time"
+)
+
+func testSchedules(t *testing.T) map[string]*Schedule {
+	d := testPlaylistDisplay()
+	schedules := map[string]*Schedule{
+		"commute":  {Days: []string{"MON", "TUE", "WED", "THU", "FRI"}, Start: "07:00", End: "09:00", Dashboard: "a"},
+		"night":    {Start: "22:00"},
+		"saturday": {Days: []string{"SAT"}, Start: "00:00", Dashboard: "b"},
+	}
+	for name, s := range schedules {
+		if err := s.Check(d.Dashboards, d.Playlists); err != nil {
+			t.Fatal(name, err)
+		}
+	}
+	return schedules
+}
+
+func TestSchedule_scheduleInEffect(t *testing.T) {
+	schedules := testSchedules(t)
+
+	// 2025-01-06 is a Monday
+	cases := map[string]time.Time{
+		"commute":  time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC),
+		"night":    time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+		"saturday": time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC),
+	}
+	for expected, now := range cases {
+		if active := scheduleInEffect(schedules, now); active != expected {
+			t.Error("expected", expected, "got", active, "at", now)
+		}
+	}
+
+	if active := scheduleInEffect(schedules, time.Date(2025, 1, 11, 23, 0, 0, 0, time.UTC)); active != "night" {
+		t.Error("expected night rule to take over on saturday evening, got", active)
+	}
+}
+
+func TestSchedule_overnight(t *testing.T) {
+	s := Schedule{Start: "22:00", End: "06:00"}
+	if err := s.Check(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.lastStart(time.Date(2025, 1, 7, 3, 0, 0, 0, time.UTC)); !ok {
+		t.Fatal("overnight rule should still be in effect after midnight")
+	}
+	if _, ok := s.lastStart(time.Date(2025, 1, 7, 7, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("overnight rule should have ended in the morning")
+	}
+}
+
+func TestSchedule_nextScheduleBoundary(t *testing.T) {
+	schedules := testSchedules(t)
+
+	next := nextScheduleBoundary(schedules, time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	if !next.Equal(time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected next boundary to be the end of the commute rule, got", next)
+	}
+	next = nextScheduleBoundary(schedules, time.Date(2025, 1, 10, 23, 0, 0, 0, time.UTC))
+	if !next.Equal(time.Date(2025, 1, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("expected next boundary to be the start of the saturday rule, got", next)
+	}
+}
+
+func TestSchedule_Check(t *testing.T) {
+	d := testPlaylistDisplay()
+	invalid := []Schedule{
+		{Start: "25:00"},
+		{Start: "07:00", End: "07:00"},
+		{Start: "07:00", Days: []string{"FUNDAY"}},
+		{Start: "07:00", Dashboard: "missing"},
+		{Start: "07:00", Dashboard: "a", Playlist: "p"},
+	}
+	for _, s := range invalid {
+		if s.Check(d.Dashboards, d.Playlists) == nil {
+			t.Error("expected schedule to fail validation", s)
+		}
+	}
+}
+
+func TestSchedule_end(t *testing.T) {
+	d := testPlaylistDisplay()
+	d.Schedules = map[string]*Schedule{
+		"commute": {Start: "07:00", End: "09:00", Dashboard: "a"},
+	}
+	if err := d.Schedules["commute"].Check(d.Dashboards, d.Playlists); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.activateDashboard("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	d.updateSchedule(time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC))
+	if d.activeSchedule != "commute" || d.activeDashboard != "a" {
+		t.Fatal("the rule should have activated its dashboard, got", d.activeSchedule, d.activeDashboard)
+	}
+
+	if blank := d.updateSchedule(time.Date(2025, 1, 6, 9, 30, 0, 0, time.UTC)); blank {
+		t.Fatal("the display should go back to its dashboard, not be blanked")
+	}
+	if d.activeSchedule != "" || d.activeDashboard != "b" {
+		t.Fatal("the dashboard from before the rule should be back once it ends, got", d.activeSchedule, d.activeDashboard)
+	}
+
+	// without anything to go back to, the display is blanked
+	d.deactivateActiveDashboard()
+	d.updateSchedule(time.Date(2025, 1, 7, 8, 0, 0, 0, time.UTC))
+	if blank := d.updateSchedule(time.Date(2025, 1, 7, 9, 30, 0, 0, time.UTC)); !blank {
+		t.Fatal("the display should be blanked once the rule ends")
+	}
+	if d.activeSchedule != "" || d.activeDashboard != "" || d.activePlaylist != "" {
+		t.Fatal("nothing should be active once the rule ends, got", d.activeSchedule, d.activeDashboard, d.activePlaylist)
+	}
+}
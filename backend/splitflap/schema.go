@@ -0,0 +1,158 @@
+package splitflap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+// SchemaVersion is the version of the config file format this backend writes. Config files from older versions are
+// upgraded when they are loaded, and the original is kept as a backup next to it
+const SchemaVersion = 3
+
+// migrations upgrade a config file from one schema version to the next: migrations[i] upgrades version i to i+1. They
+// work on the generic JSON of the file, as the structs it was written from may not exist anymore. Add a migration (and
+// bump SchemaVersion) whenever a change would stop older config files from loading, or change what they mean
+var migrations = []func(config map[string]any) error{
+	migrateToMultipleDisplays,
+	migrateDaysUntilDates,
+	migrateMissingPollRates,
+}
+
+// migrateConfig upgrades a config file to SchemaVersion, returning the upgraded file and the version it was upgraded
+// from. A file that is already up to date is returned as is
+func migrateConfig(data []byte) ([]byte, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // so that numbers come out exactly as they went in
+	var config map[string]any
+	if err := decoder.Decode(&config); err != nil {
+		return nil, 0, describeJSONError(err)
+	}
+
+	version := 0
+	if v, ok := config["schema_version"]; ok {
+		n, err := strconv.Atoi(jsonString(v))
+		if err != nil || n < 0 {
+			return nil, 0, errors.New("schema_version must be a whole number")
+		}
+		version = n
+	}
+	if version > SchemaVersion {
+		return nil, 0, errors.New("schema_version " + strconv.Itoa(version) + " is newer than this backend supports (" +
+			strconv.Itoa(SchemaVersion) + "); upgrade the backend to load it")
+	}
+	if version == SchemaVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](config); err != nil {
+			return nil, 0, errors.New("upgrading schema_version " + strconv.Itoa(v) + " to " + strconv.Itoa(v+1) + ": " + err.Error())
+		}
+	}
+	config["schema_version"] = SchemaVersion
+	migrated, err := json.Marshal(config)
+	return migrated, version, err
+}
+
+// migrateToMultipleDisplays (0 -> 1) moves the single display of a config file from before multiple displays were
+// supported to the displays map, as the display named DefaultDisplayID. The providers stay where they are, as they are
+// shared by every display
+func migrateToMultipleDisplays(config map[string]any) error {
+	if _, ok := config["displays"]; ok {
+		return nil
+	}
+	d := make(map[string]any)
+	for key, value := range config {
+		if key != "providers" && key != "schema_version" {
+			d[key] = value
+			delete(config, key)
+		}
+	}
+	config["displays"] = map[string]any{DefaultDisplayID: d}
+	return nil
+}
+
+// migrateDaysUntilDates (1 -> 2) changes the end dates of DAYSUNTIL routines from MM/DD/YYYY to YYYY-MM-DD. Dates that
+// don't parse are left alone, and are reported when the routine is checked
+func migrateDaysUntilDates(config map[string]any) error {
+	forEachRoutine(config, func(rout map[string]any) {
+		routineConfig, ok := rout["config"].(map[string]any)
+		if rout["type"] != routine.DAYSUNTIL || !ok {
+			return
+		}
+		end, ok := routineConfig["end_date"].(string)
+		if !ok {
+			return
+		}
+		if date, err := time.Parse("01/02/2006", end); err == nil {
+			routineConfig["end_date"] = date.Format(time.DateOnly)
+		}
+	})
+	return nil
+}
+
+// migrateMissingPollRates (2 -> 3) gives displays that were saved without a poll rate (which older versions did for new
+// displays) the default one, as they would otherwise fail to load
+func migrateMissingPollRates(config map[string]any) error {
+	displays, _ := config["displays"].(map[string]any)
+	for _, d := range displays {
+		d, ok := d.(map[string]any)
+		if !ok {
+			continue
+		}
+		if rate := jsonString(d["poll_rate_ms"]); rate == "" || rate == "0" {
+			d["poll_rate_ms"] = DefaultPollRateMs
+		}
+	}
+	return nil
+}
+
+// forEachRoutine calls f with the JSON of every routine of every dashboard of every display
+func forEachRoutine(config map[string]any, f func(rout map[string]any)) {
+	displays, _ := config["displays"].(map[string]any)
+	for _, d := range displays {
+		d, _ := d.(map[string]any)
+		dashboards, _ := d["dashboards"].(map[string]any)
+		for _, dashboard := range dashboards {
+			dashboard, _ := dashboard.(map[string]any)
+			routines, _ := dashboard["routines"].([]any)
+			for _, rout := range routines {
+				if rout, ok := rout.(map[string]any); ok {
+					f(rout)
+				}
+			}
+		}
+	}
+}
+
+// jsonString returns a JSON scalar decoded with UseNumber as a string, or "" if it is missing or not a scalar
+func jsonString(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	}
+	return ""
+}
+
+// describeJSONError rewords the errors of encoding/json to point at the JSON, rather than at the Go types it was being
+// decoded into
+func describeJSONError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return errors.New("field " + typeErr.Field + ": expected " + typeErr.Type.String() + ", got " + typeErr.Value)
+	case errors.As(err, &typeErr):
+		return errors.New("expected " + typeErr.Type.String() + ", got " + typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return errors.New("invalid JSON at byte " + strconv.FormatInt(syntaxErr.Offset, 10) + ": " + syntaxErr.Error())
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package splitflap
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/routine"
+)
+
+const unversionedDisplayJSON = `{
+  "size": {"width": 4, "height": 1},
+  "translations": {},
+  "providers": {},
+  "dashboards": {"countdown": {"routines": [
+    {"type": "DAYSUNTIL", "location": {"x": 0, "y": 0}, "size": {"width": 4, "height": 1}, "config": {"end_date": "12/25/2030"}}
+  ]}},
+  "layout": [0, 1, 2, 3]
+}`
+
+func TestLoadHubFromFile_migrates(t *testing.T) {
+	path := writeTestFile(t, unversionedDisplayJSON)
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := hub.Display(DefaultDisplayID)
+	if d.PollRate != DefaultPollRateMs {
+		t.Fatal("a display saved without a poll rate should get the default one")
+	}
+	if end := d.Dashboards["countdown"].Routines[0].Routine.(*routine.DaysUntilRoutine).End; end != "2030-12-25" {
+		t.Fatal("DAYSUNTIL end dates should be upgraded to YYYY-MM-DD", end)
+	}
+
+	backup, err := os.ReadFile(path + ".v0.bak")
+	if err != nil || string(backup) != unversionedDisplayJSON {
+		t.Fatal("the original file should be kept as a backup", err)
+	}
+	upgraded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err = json.Unmarshal(upgraded, &version); err != nil || version.SchemaVersion != SchemaVersion {
+		t.Fatal("the upgraded file should be saved with the current schema version", err)
+	}
+
+	// an up to date file is loaded as is
+	if _, err = LoadHubFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path + ".v" + strconv.Itoa(SchemaVersion) + ".bak"); err == nil {
+		t.Fatal("an up to date file shouldn't be backed up")
+	}
+}
+
+func TestLoadHubFromFile_upgradeNotSaved(t *testing.T) {
+	path := writeTestFile(t, unversionedDisplayJSON)
+	// the backup can't be written where a directory is in the way
+	if err := os.Mkdir(path+".v0.bak", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hub, err := LoadHubFromFile(path)
+	if err != nil {
+		t.Fatal("a config that can't be saved after upgrading should still be loaded", err)
+	}
+	d, _ := hub.Display(DefaultDisplayID)
+	if end := d.Dashboards["countdown"].Routines[0].Routine.(*routine.DaysUntilRoutine).End; end != "2030-12-25" {
+		t.Fatal("the config should be upgraded in memory", end)
+	}
+	if original, _ := os.ReadFile(path); string(original) != unversionedDisplayJSON {
+		t.Fatal("the original file should be left alone if it can't be backed up")
+	}
+}
+
+func TestLoadHubFromFile_errors(t *testing.T) {
+	const textRoutine = `{"type": "TEXT", "size": {"width": 4, "height": 1}, "config": {"text": "HI"}}`
+	config := func(providers, routines string) string {
+		return `{"schema_version": 3, "providers": {` + providers + `}, "displays": {"lobby": {
+  "size": {"width": 4, "height": 1}, "layout": [0, 1, 2, 3], "poll_rate_ms": 100,
+  "dashboards": {"main": {"routines": [` + routines + `]}}
+}}}`
+	}
+
+	for _, test := range []struct {
+		config, err string
+	}{
+		{
+			config(``, textRoutine+`, {"type": "NOPE", "config": {}}`),
+			`display "lobby": dashboard "main": routine 1: unrecognized routine type "NOPE"`,
+		},
+		{
+			config(``, `{"type": "TEXT", "size": {"width": 4, "height": 1}, "config": {"text": 5}}`),
+			`display "lobby": dashboard "main": routine 0 (TEXT): field config.text: expected string, got number`,
+		},
+		{
+			config(``, `{"type": "DAYSUNTIL", "size": {"width": 4, "height": 1}, "config": {"end_date": "Christmas"}}`),
+			`display "lobby": dashboard "main": routine 0 (DAYSUNTIL): field config.end_date: must be a date in YYYY-MM-DD or MM/DD/YYYY format`,
+		},
+		{
+			config(`"sky": {"type": "NOPE", "config": {}}`, textRoutine),
+			`provider "sky": unrecognized provider type "NOPE"`,
+		},
+		{
+			config(`"weather": {"type": "WEATHER_CURRENT", "config": {"location_id": "here", "units": "C"}}`, textRoutine),
+			`provider "weather": field config.location_id: expected int, got string`,
+		},
+		{
+			`{"schema_version": 99, "displays": {}}`,
+			`schema_version 99 is newer than this backend supports`,
+		},
+	} {
+		_, err := LoadHubFromFile(writeTestFile(t, test.config))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("expected error %q, got %v", test.err, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package transition
+
+import (
+	"time"
+
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+)
+
+// Motion estimates what it costs the display to change from one text to another. Modules only move forwards, so
+// changing to an earlier character costs nearly a full rotation
+type Motion struct {
+	Flaps    []int `json:"flaps"`     // how many flaps each module advances
+	Total    int   `json:"total"`     // flaps advanced across every module, i.e. the wear the change causes
+	Changed  int   `json:"changed"`   // how many modules change character
+	SettleMs int64 `json:"settle_ms"` // roughly how long until every module has landed
+}
+
+// SettleTime is roughly how long until every module has landed
+func (m Motion) SettleTime() time.Duration {
+	return time.Duration(m.SettleMs) * time.Millisecond
+}
+
+// EstimateMotion estimates the cost of changing the display from one text to another. Modules that are missing from
+// from are assumed to be blank, and modules that are usb_serial.HoldCharacter in to don't move
+func EstimateMotion(from, to string) Motion {
+	current := []rune(from)
+	target := []rune(to)
+
+	m := Motion{Flaps: make([]int, len(target))}
+	maxFlaps := 0
+	for i, c := range target {
+		if c == rune(usb_serial.HoldCharacter) {
+			continue
+		}
+		prev := ' '
+		if i < len(current) {
+			prev = current[i]
+		}
+		flaps := usb_serial.AlphabetDistance(prev, c)
+		m.Flaps[i] = flaps
+		m.Total += flaps
+		if flaps > 0 {
+			m.Changed++
+		}
+		maxFlaps = max(maxFlaps, flaps)
+	}
+	m.SettleMs = (time.Duration(maxFlaps) * FlapDuration).Milliseconds()
+	return m
+}
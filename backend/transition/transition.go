@@ -0,0 +1,175 @@
+package transition
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+)
+
+// Effect is the name of a transition, i.e. the order and timing in which modules are sent to their new characters
+type Effect string
+
+const (
+	None    Effect = ""
+	Wipe    Effect = "WIPE"     // columns change one at a time, from left to right
+	Cascade Effect = "CASCADE"  // modules change one at a time, top to bottom within a column, column by column
+	Random  Effect = "RANDOM"   // modules change one at a time, in a random order
+	SpinAll Effect = "SPIN_ALL" // every module does a full rotation before settling, even if its character didn't change
+	Stagger Effect = "STAGGER"  // modules start moving so that they arrive one after another, from left to right
+)
+
+// All lists every effect, with a description for clients to show
+var All = map[Effect]string{
+	Wipe:    "Reveal the new text column by column, from left to right",
+	Cascade: "Reveal the new text one module at a time, falling down each column in turn",
+	Random:  "Reveal the new text one module at a time, in a random order",
+	SpinAll: "Spin every module through a full rotation before settling on the new text",
+	Stagger: "Time each module's start so the characters arrive one after another, from left to right",
+}
+
+const (
+	// FlapDuration is roughly how long a module takes to advance a single flap
+	FlapDuration = time.Millisecond * 65
+
+	wipeColumnDelay    = time.Millisecond * 150
+	cascadeModuleDelay = time.Millisecond * 60
+	randomDuration     = time.Millisecond * 1500
+	staggerLetterDelay = time.Millisecond * 150
+)
+
+// Frame is one step of a transition: text to send to the display (where modules that shouldn't change yet are
+// usb_serial.HoldCharacter), after waiting Delay since the previous frame
+type Frame struct {
+	Text     string
+	Movement usb_serial.ForceMovement
+	Delay    time.Duration
+}
+
+func Check(effect Effect) error {
+	if effect == None {
+		return nil
+	}
+	if _, ok := All[effect]; !ok {
+		return errors.New("unrecognized transition: " + string(effect))
+	}
+	return nil
+}
+
+// Frames breaks the change of a display from one text to another into the frames of an effect. Both texts cover the
+// whole display, in reading order. from is only used for timing, and may be empty if the current text is unknown. The
+// last frame is always the complete target text
+func Frames(effect Effect, size display.Size, from, to string) ([]Frame, error) {
+	if err := Check(effect); err != nil {
+		return nil, err
+	}
+	target := []rune(to)
+	if len(target) != size.Width*size.Height {
+		return nil, errors.New("transition text does not match the display size")
+	}
+
+	switch effect {
+	case Wipe:
+		order := make([][]int, size.Width)
+		for i := range target {
+			order[i%size.Width] = append(order[i%size.Width], i)
+		}
+		return reveal(target, order, wipeColumnDelay), nil
+	case Cascade:
+		order := make([][]int, 0, len(target))
+		for x := range size.Width {
+			for y := range size.Height {
+				order = append(order, []int{y*size.Width + x})
+			}
+		}
+		return reveal(target, order, cascadeModuleDelay), nil
+	case Random:
+		order := make([][]int, len(target))
+		for i, pos := range rand.Perm(len(target)) {
+			order[i] = []int{pos}
+		}
+		return reveal(target, order, randomDuration/time.Duration(len(target))), nil
+	case SpinAll:
+		return []Frame{{Text: to, Movement: usb_serial.ForceMovementAll}}, nil
+	case Stagger:
+		return stagger(target, []rune(from)), nil
+	}
+	return []Frame{{Text: to}}, nil
+}
+
+// reveal sends each group of positions to its target, one group per frame, holding the positions not yet revealed
+func reveal(target []rune, order [][]int, delay time.Duration) []Frame {
+	text := make([]rune, len(target))
+	for i := range text {
+		text[i] = rune(usb_serial.HoldCharacter)
+	}
+
+	frames := make([]Frame, 0, len(order))
+	for i, positions := range order {
+		for _, pos := range positions {
+			text[pos] = target[pos]
+		}
+		frame := Frame{Text: string(text)}
+		if i > 0 {
+			frame.Delay = delay
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// StaggerStarts returns when each module has to start moving, relative to the first module to start, so that module i
+// arrives letterDelay after module i-1. Modules only move forwards, so a module that is far from its character has to
+// start early. from may be shorter than to, in which case the missing modules are assumed to be blank
+func StaggerStarts(from, to []rune, letterDelay time.Duration) []time.Duration {
+	starts := make([]time.Duration, len(to))
+	earliest := time.Duration(0)
+	for i, c := range to {
+		current := ' '
+		if i < len(from) {
+			current = from[i]
+		}
+		travel := time.Duration(usb_serial.AlphabetDistance(current, c)) * FlapDuration
+		starts[i] = time.Duration(i)*letterDelay - travel
+		earliest = min(earliest, starts[i])
+	}
+	for i := range starts {
+		starts[i] -= earliest
+	}
+	return starts
+}
+
+// stagger reveals each module at the time given by StaggerStarts
+func stagger(target, from []rune) []Frame {
+	starts := StaggerStarts(from, target, staggerLetterDelay)
+	positions := make([]int, len(target))
+	for i := range positions {
+		positions[i] = i
+	}
+	slices.SortStableFunc(positions, func(a, b int) int {
+		return int(starts[a] - starts[b])
+	})
+
+	var order [][]int
+	var delays []time.Duration
+	last := time.Duration(0)
+	for _, pos := range positions {
+		if len(order) > 0 && starts[pos] == last {
+			order[len(order)-1] = append(order[len(order)-1], pos)
+			continue
+		}
+		order = append(order, []int{pos})
+		delays = append(delays, starts[pos]-last)
+		last = starts[pos]
+	}
+
+	frames := reveal(target, order, 0)
+	for i := range frames {
+		frames[i].Delay = delays[i]
+	}
+	frames[0].Delay = 0
+	return frames
+}
@@ -0,0 +1,108 @@
+package transition
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/denverquane/go-splitflap/display"
+	"github.com/denverquane/go-splitflap/serdiev/usb_serial"
+	"github.com/go-playground/assert/v2"
+)
+
+func TestFrames_wipe(t *testing.T) {
+	frames, err := Frames(Wipe, display.Size{Width: 3, Height: 2}, "", "ABCDEF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"A__D__", "AB_DE_", "ABCDEF"}, frameTexts(frames))
+	assert.Equal(t, 0, int(frames[0].Delay))
+	assert.Equal(t, wipeColumnDelay, frames[1].Delay)
+}
+
+func TestFrames_cascade(t *testing.T) {
+	frames, err := Frames(Cascade, display.Size{Width: 2, Height: 2}, "", "ABCD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"A___", "A_C_", "ABC_", "ABCD"}, frameTexts(frames))
+}
+
+func TestFrames_random(t *testing.T) {
+	frames, err := Frames(Random, display.Size{Width: 5, Height: 1}, "", "HELLO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, len(frames))
+	assert.Equal(t, "HELLO", frames[len(frames)-1].Text)
+}
+
+func TestFrames_spinAll(t *testing.T) {
+	frames, err := Frames(SpinAll, display.Size{Width: 2, Height: 1}, "HI", "HI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(frames))
+	assert.Equal(t, usb_serial.ForceMovementAll, frames[0].Movement)
+}
+
+func TestFrames_stagger(t *testing.T) {
+	alphabet := usb_serial.GlobalAlphabet
+	usb_serial.GlobalAlphabet = []rune(" ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	defer func() { usb_serial.GlobalAlphabet = alphabet }()
+
+	// B is one flap from A, so it has to start well after Z, which is a long way round
+	frames, err := Frames(Stagger, display.Size{Width: 2, Height: 1}, "AA", "ZB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"Z_", "ZB"}, frameTexts(frames))
+	assert.Equal(t, staggerLetterDelay+FlapDuration*(25-1), frames[1].Delay)
+
+	// Z has to arrive after B, but is so far round that it still starts first
+	frames, err = Frames(Stagger, display.Size{Width: 2, Height: 1}, "AA", "BZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"_Z", "BZ"}, frameTexts(frames))
+	assert.Equal(t, FlapDuration*(25-1)-staggerLetterDelay, frames[1].Delay)
+}
+
+func TestFrames_invalid(t *testing.T) {
+	if _, err := Frames("SIDEWAYS", display.Size{Width: 2, Height: 1}, "", "HI"); err == nil {
+		t.Fatal("unknown effects should be rejected")
+	}
+	if _, err := Frames(Wipe, display.Size{Width: 2, Height: 1}, "", "HELLO"); err == nil {
+		t.Fatal("text that doesn't fit the display should be rejected")
+	}
+	frames, err := Frames(None, display.Size{Width: 2, Height: 1}, "", "HI")
+	if err != nil || len(frames) != 1 || frames[0].Text != "HI" {
+		t.Fatal("no effect should send the text as a single frame")
+	}
+}
+
+// frameTexts returns the text of each frame, with held modules shown as _
+func frameTexts(frames []Frame) []string {
+	texts := make([]string, len(frames))
+	for i, f := range frames {
+		texts[i] = strings.ReplaceAll(f.Text, string(rune(usb_serial.HoldCharacter)), "_")
+	}
+	return texts
+}
+
+func TestEstimateMotion(t *testing.T) {
+	alphabet := usb_serial.GlobalAlphabet
+	usb_serial.GlobalAlphabet = []rune(" ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	defer func() { usb_serial.GlobalAlphabet = alphabet }()
+
+	// Z to Y goes nearly all the way round, B to B doesn't move and held modules never move
+	m := EstimateMotion("ZBA", "YB"+string(rune(usb_serial.HoldCharacter)))
+	assert.Equal(t, []int{26, 0, 0}, m.Flaps)
+	assert.Equal(t, 26, m.Total)
+	assert.Equal(t, 1, m.Changed)
+	assert.Equal(t, 26*FlapDuration, m.SettleTime())
+
+	// modules missing from the current text are blank
+	m = EstimateMotion("", "AC")
+	assert.Equal(t, []int{1, 3}, m.Flaps)
+	assert.Equal(t, 3*FlapDuration, m.SettleTime())
+}
@@ -5,9 +5,26 @@ import { useState, useEffect, useRef, createContext, useContext } from 'react';
  */
 export interface DisplayState {
   activeDashboard: string;
+  activePlaylist?: string; // "" when no playlist is rotating dashboards
+  playlistStep?: number; // index of the playlist step being shown, -1 when no playlist is active
+  activeAlert?: {
+    id: string;
+    text: string;
+    priority: number;
+    ttl_secs: number;
+    source: string;
+    created: string;
+    expires: string;
+  }; // the alert currently covering the dashboard, if any
   currentTime: string;
   displayState?: string; // The current display state as a string of characters
   state?: string; // Backend calls it "state" but we use "displayState" for clarity
+  connection?: {
+    connected: boolean; // false when the hardware is offline (or the backend runs without hardware)
+    since: string;
+    reconnect_attempts: number;
+    last_error: string;
+  };
 }
 
 interface WebSocketContextType {
@@ -144,6 +161,7 @@ export function useActiveState() {
   
   return {
     activeDashboard: displayState?.activeDashboard || "",
+    activePlaylist: displayState?.activePlaylist || "",
     isConnected
   };
 }
\ No newline at end of file
@@ -20,7 +20,7 @@ export const TimerRoutineSchema = z.object({
 
 export const SlowTextSchema = z.object({
   text: z.string(),
-  delay: z.number(),
+  letter_delay_ms: z.number(),
 });
 
 export const WeatherRoutineSchema = z.object({
@@ -214,7 +214,7 @@ export default function DashboardDetailPage() {
                           <span className="font-semibold">Text:</span> {routine.routine.text}
                         </div>
                         <div className="mb-2">
-                          <span className="font-semibold">Delay:</span> {routine.routine.delay}ms
+                          <span className="font-semibold">Delay:</span> {routine.routine.letter_delay_ms}ms
                         </div>
                       </div>
                     )}